@@ -1,6 +0,0 @@
-package cache
-
-import "time"
-
-const maxSkipInvalidations = 6
-const invalidationSleepTime = 5 * time.Second
@@ -1,36 +1,93 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
-	"sync"
 	"time"
 
+	"github.com/foomo/neosproxy/cache/export"
+	"github.com/foomo/neosproxy/client/cms"
 	"github.com/foomo/neosproxy/config"
 	"github.com/foomo/neosproxy/logging"
 )
 
-// New will return a newly created cache object
-func New(broker Broker, workspace string, cfg *config.Config) *Cache {
+// New will return a newly created cache object, exports are loaded from NEOS with the given loader
+func New(broker Broker, loader cms.RepositoryLoader, workspace string, cfg *config.Config) *Cache {
 
-	cacheDir := filepath.Join(cfg.Cache.Directory, "cse")
+	cacheDir := ExportDirectory(cfg.Cache.Directory)
 
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		logging.GetDefaultLogEntry().WithError(err).Fatal("failed creating cache directory")
 	}
 
+	validation, errValidation := export.NewRules(cfg.Cache.Validation, cfg.Neos.Dimensions)
+	if errValidation != nil {
+		logging.GetDefaultLogEntry().WithError(errValidation).Fatal("failed loading contentserver export validation rules")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	c := &Cache{
 		Workspace:           workspace,
 		invalidationChannel: make(chan time.Time, 1),
+		debounce:            cfg.Cache.Debounce.Workspace(workspace),
+
+		broker:      broker,
+		file:        ExportFilename(cfg.Cache.Directory, workspace),
+		versionsDir: ExportVersionsDirectory(cfg.Cache.Directory, workspace),
 
-		broker:   broker,
-		file:     fmt.Sprintf("%s/contentserver-export-%s.json", cacheDir, workspace),
-		FileLock: sync.RWMutex{},
+		historyDir:  ExportHistoryDirectory(cfg.Cache.Directory, workspace),
+		historySize: cfg.Cache.ExportHistory,
+		deltas:      map[string]*ExportDelta{},
+
+		ready:      make(chan struct{}),
+		validation: validation,
 
 		neos:   cfg.Neos,
 		config: cfg.Cache,
+
+		ctx:    ctx,
+		cancel: cancel,
+		loader: loader,
+	}
+
+	// partial files of an interrupted run
+	for _, suffix := range []string{".download", ".rollback", ".load", ".link"} {
+		if err := os.Remove(c.file + suffix); err == nil {
+			logging.GetDefaultLogEntry().WithField(logging.FieldWorkspace, workspace).WithField("file", c.file+suffix).Info("removed partial contentserver export")
+		}
+	}
+	if err := c.loadCurrentExport(); err != nil {
+		logging.GetDefaultLogEntry().WithError(err).WithField(logging.FieldWorkspace, workspace).Error("failed loading contentserver export")
+	}
+	if err := c.loadExportHistory(); err != nil {
+		logging.GetDefaultLogEntry().WithError(err).WithField(logging.FieldWorkspace, workspace).Error("failed loading contentserver export history")
+	}
+	if err := c.loadUpstreamVersion(); err != nil {
+		logging.GetDefaultLogEntry().WithError(err).WithField(logging.FieldWorkspace, workspace).Error("failed loading upstream version of contentserver export")
+	}
+	if err := c.loadFrozen(); err != nil {
+		logging.GetDefaultLogEntry().WithError(err).WithField(logging.FieldWorkspace, workspace).Error("failed loading frozen state of contentserver export")
+	}
+	if c.hasExport() {
+		c.markReady()
 	}
 	go c.scheduleInvalidation()
 	return c
 }
+
+// Close stops invalidations and cancels a running download
+func (c *Cache) Close() {
+	c.cancel()
+}
+
+// ExportDirectory returns the directory of all cached contentserver exports
+func ExportDirectory(cacheDirectory string) string {
+	return filepath.Join(cacheDirectory, "cse")
+}
+
+// ExportFilename returns the file of a cached contentserver export of a workspace
+func ExportFilename(cacheDirectory string, workspace string) string {
+	return fmt.Sprintf("%s/contentserver-export-%s.json", ExportDirectory(cacheDirectory), workspace)
+}
@@ -10,14 +10,21 @@ import (
 	"golang.org/x/sync/singleflight"
 )
 
-// New will return a newly created content cache
-func New(cacheLifetime time.Duration, store store.CacheStore, loader cms.ContentLoader, observer Observer, log logging.Entry) *Cache {
+// New will return a newly created content cache keeping the last historySize versions of every document
+// stores implementing store.VersionStore keep the versions, otherwise they are kept in process memory
+func New(cacheLifetime time.Duration, historySize int, cacheStore store.CacheStore, loader cms.ContentLoader, observer Observer, log logging.Entry) *Cache {
+	versions, ok := cacheStore.(store.VersionStore)
+	if !ok {
+		versions = newMemoryVersions(maxHistoryBytes)
+	}
+
 	c := &Cache{
 		observer: observer,
 		loader:   loader,
-		store:    store,
+		store:    cacheStore,
 
 		cacheDependencies: NewCacheDependencies(),
+		history:           newHistory(historySize, versions),
 
 		invalidationRequestGroup: &singleflight.Group{},
 		invalidationChannel:      make(chan InvalidationRequest, 10000),
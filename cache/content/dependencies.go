@@ -7,33 +7,41 @@ import "sync"
 //-----------------------------------------------------------------------------
 
 type cacheDependencies struct {
-	dependencies map[string]*cacheDependency
+	lock         sync.RWMutex
+	dependencies map[dependencyScope]*cacheDependency
+}
+
+// dependencyScope of a dimension in a workspace
+type dependencyScope struct {
+	Dimension string
+	Workspace string
 }
 
 func NewCacheDependencies() *cacheDependencies {
 	return &cacheDependencies{
-		dependencies: make(map[string]*cacheDependency, 4),
+		dependencies: make(map[dependencyScope]*cacheDependency, 4),
 	}
 }
 
-func (c *cacheDependencies) getHash(dimension, workspace string) string {
-	return workspace + "_" + dimension
-}
-
 func (c *cacheDependencies) Get(id, dimension, workspace string) []string {
-	hash := c.getHash(dimension, workspace)
-	if cache, ok := c.dependencies[hash]; ok {
+	c.lock.RLock()
+	cache, ok := c.dependencies[dependencyScope{Dimension: dimension, Workspace: workspace}]
+	c.lock.RUnlock()
+	if ok {
 		return cache.Get(id)
 	}
 	return nil
 }
 
 func (c *cacheDependencies) Set(sourceID, targetID, dimension, workspace string) {
-	hash := c.getHash(dimension, workspace)
-	if _, ok := c.dependencies[hash]; !ok {
-		c.dependencies[hash] = &cacheDependency{}
+	scope := dependencyScope{Dimension: dimension, Workspace: workspace}
+	c.lock.Lock()
+	cache, ok := c.dependencies[scope]
+	if !ok {
+		cache = &cacheDependency{}
+		c.dependencies[scope] = cache
 	}
-	cache := c.dependencies[hash]
+	c.lock.Unlock()
 	cache.Set(sourceID, targetID)
 	return
 }
@@ -20,3 +20,16 @@ func TestDependencies(t *testing.T) {
 		t.Fatal("unexpected dependency")
 	}
 }
+
+func TestDependenciesScope(t *testing.T) {
+	deps := NewCacheDependencies()
+	deps.Set("abc", "123", "de_CH", "live")
+	deps.Set("def", "123", "CH", "live_de")
+
+	if d := deps.Get("123", "de_CH", "live"); len(d) != 1 || d[0] != "abc" {
+		t.Fatal("unexpected dependencies", d)
+	}
+	if d := deps.Get("123", "CH", "live_de"); len(d) != 1 || d[0] != "def" {
+		t.Fatal("unexpected dependencies", d)
+	}
+}
@@ -5,5 +5,11 @@ import "errors"
 // ErrorNotFound error in case of no cache hit
 var ErrorNotFound = errors.New("cache item not found")
 
+// ErrorWriteFailed error in case fresh content could not be written to the store
+var ErrorWriteFailed = errors.New("cache item could not be written to store")
+
+// ErrorVersionNotFound error in case of an unknown document version
+var ErrorVersionNotFound = errors.New("cache item version not found")
+
 // ErrorInvalidationRejectedQueueExhausted error in case invalidation queue is full
 var ErrorInvalidationRejectedQueueExhausted = errors.New("invalidation request rejected: invalidation queue capacity exhausted")
@@ -1,10 +1,13 @@
 package content
 
 import (
+	"time"
+
 	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/model"
 )
 
-// Get a cache item, if it exists
+// Get a cache item to serve it, if it exists
 func (c *Cache) Get(id, dimension, workspace string) (item store.CacheItem, err error) {
 
 	hash := store.GetHash(id, dimension, workspace)
@@ -18,6 +21,22 @@ func (c *Cache) Get(id, dimension, workspace string) (item store.CacheItem, err
 	return
 }
 
+// Peek returns a cache item without marking it as served, if it exists
+func (c *Cache) Peek(id, dimension, workspace string) (item store.CacheItem, err error) {
+	return c.peek(store.GetHash(id, dimension, workspace))
+}
+
+// GetInfo returns the metadata of a cache item, if it exists
+func (c *Cache) GetInfo(id, dimension, workspace string) (info store.CacheItemInfo, err error) {
+	item, errGet := c.Peek(id, dimension, workspace)
+	if errGet != nil {
+		err = errGet
+		return
+	}
+	info = store.NewCacheItemInfo(item)
+	return
+}
+
 // Len will return the number of cached items
 func (c *Cache) Len() int {
 	counter, errCounter := c.store.Count()
@@ -39,3 +58,63 @@ func (c *Cache) GetAllEtags(workspace string) (etags map[string]string) {
 func (c *Cache) GetEtag(hash string) (etag string, e error) {
 	return c.store.GetEtag(hash)
 }
+
+// Status returns the state of the content cache, including store usage if available
+func (c *Cache) Status() model.ContentCacheStatus {
+	c.lockStatus.RLock()
+	status := c.status
+	c.lockStatus.RUnlock()
+
+	if reporter, ok := c.store.(store.UsageReporter); ok {
+		usage := reporter.Usage()
+		status.Items = usage.Items
+		status.Bytes = usage.Bytes
+		status.Quota = usage.Quota
+		status.Evictions = usage.Evictions
+		return status
+	}
+
+	status.Items = c.Len()
+	return status
+}
+
+// peek reads a cache item for internal lookups, stores evicting least recently served items are not touched
+func (c *Cache) peek(hash string) (store.CacheItem, error) {
+	if peeker, ok := c.store.(store.Peeker); ok {
+		return peeker.Peek(hash)
+	}
+	return c.store.Get(hash)
+}
+
+func (c *Cache) writeFailed(err error) {
+	c.lockStatus.Lock()
+	if !c.status.Degraded {
+		c.log.WithError(err).Error("content cache store write failed, serving uncached content (degraded)")
+	}
+	c.status.Degraded = true
+	c.status.WriteErrors++
+	c.status.LastWriteError = err.Error()
+	c.status.LastWriteErrorAt = time.Now()
+	c.lockStatus.Unlock()
+}
+
+func (c *Cache) writeSucceeded() {
+	c.lockStatus.RLock()
+	degraded := c.status.Degraded
+	c.lockStatus.RUnlock()
+	if !degraded {
+		return
+	}
+
+	c.lockStatus.Lock()
+	if c.status.Degraded {
+		c.log.Info("content cache store writes recovered")
+	}
+	c.status.Degraded = false
+	c.lockStatus.Unlock()
+}
+
+// List returns metadata of cached items matching a query sorted by hash
+func (c *Cache) List(query store.Query) ([]store.CacheItemInfo, error) {
+	return store.List(c.store, query)
+}
@@ -0,0 +1,265 @@
+package content
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/sirupsen/logrus"
+)
+
+//-----------------------------------------------------------------------------
+// ~ VERSION HISTORY of cached documents
+//-----------------------------------------------------------------------------
+
+// Version of a cached document
+type Version struct {
+	Version      int // sequence number, increasing per document
+	Etag         string
+	HTML         string `json:"-"`
+	Dependencies []string
+	Created      time.Time
+	Pinned       bool // document has been rolled back to this version
+}
+
+// history of cached documents, kept by a version store
+type history struct {
+	size     int // number of versions to keep per document
+	versions store.VersionStore
+}
+
+// memoryVersions keeps the version history in process memory, it is lost on restart and not shared with other instances
+// least recently changed documents are dropped to keep the html of all versions within maxBytes
+type memoryVersions struct {
+	lock      sync.Mutex
+	maxBytes  int
+	bytes     int
+	documents map[string]*list.Element // hash => *versionedDocument
+	lru       *list.List               // least recently changed document first
+}
+
+type versionedDocument struct {
+	hash     string
+	versions []store.Version // oldest first
+	pinned   int
+	bytes    int // html of all versions
+}
+
+// maxHistoryBytes limits the html kept by the version history in process memory
+const maxHistoryBytes = 256 << 20
+
+func newHistory(size int, versions store.VersionStore) *history {
+	return &history{
+		size:     size,
+		versions: versions,
+	}
+}
+
+// add a version for a cache item, unless it equals the latest version
+func (h *history) add(item store.CacheItem) error {
+	if h.size <= 0 {
+		return nil
+	}
+	return h.versions.AddVersion(item.Hash, store.Version{
+		Etag:         item.GetEtag(),
+		HTML:         item.HTML,
+		Dependencies: item.Dependencies,
+		Created:      item.Created,
+	}, h.size)
+}
+
+// seed history with a cache item, if there is no history for it yet
+func (h *history) seed(item store.CacheItem) error {
+	if h.size <= 0 {
+		return nil
+	}
+	versions, _, err := h.versions.GetVersions(item.Hash)
+	if err != nil || len(versions) > 0 {
+		return err
+	}
+	return h.add(item)
+}
+
+func (h *history) get(hash string) ([]Version, error) {
+	stored, pinned, err := h.versions.GetVersions(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]Version, len(stored))
+	for i, version := range stored {
+		versions[i] = Version{
+			Version:      version.Version,
+			Etag:         version.Etag,
+			HTML:         version.HTML,
+			Dependencies: version.Dependencies,
+			Created:      version.Created,
+			Pinned:       version.Version == pinned,
+		}
+	}
+	return versions, nil
+}
+
+func (h *history) getVersion(hash string, number int) (version Version, err error) {
+	versions, errVersions := h.get(hash)
+	if errVersions != nil {
+		err = errVersions
+		return
+	}
+	for _, v := range versions {
+		if v.Version == number {
+			version = v
+			return
+		}
+	}
+	err = ErrorVersionNotFound
+	return
+}
+
+func (h *history) pin(hash string, number int) error {
+	return h.versions.PinVersion(hash, number)
+}
+
+func (h *history) removeAll() error {
+	return h.versions.RemoveAllVersions()
+}
+
+func newMemoryVersions(maxBytes int) *memoryVersions {
+	return &memoryVersions{
+		maxBytes:  maxBytes,
+		documents: map[string]*list.Element{},
+		lru:       list.New(),
+	}
+}
+
+func (m *memoryVersions) AddVersion(hash string, version store.Version, size int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	element, ok := m.documents[hash]
+	if !ok {
+		element = m.lru.PushBack(&versionedDocument{hash: hash})
+		m.documents[hash] = element
+	}
+	document := element.Value.(*versionedDocument)
+	document.pinned = 0
+
+	version.Version = 1
+	if len(document.versions) > 0 {
+		latest := document.versions[len(document.versions)-1]
+		if latest.Etag == version.Etag {
+			return nil
+		}
+		version.Version = latest.Version + 1
+	}
+	document.versions = append(document.versions, version)
+	if len(document.versions) > size {
+		document.versions = document.versions[len(document.versions)-size:]
+	}
+
+	m.bytes -= document.bytes
+	document.bytes = 0
+	for _, v := range document.versions {
+		document.bytes += len(v.HTML)
+	}
+	m.bytes += document.bytes
+	m.lru.MoveToBack(element)
+
+	// the latest document is kept in any case
+	for m.bytes > m.maxBytes && m.lru.Front() != element {
+		evicted := m.lru.Remove(m.lru.Front()).(*versionedDocument)
+		delete(m.documents, evicted.hash)
+		m.bytes -= evicted.bytes
+	}
+	return nil
+}
+
+func (m *memoryVersions) GetVersions(hash string) (versions []store.Version, pinned int, e error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	element, ok := m.documents[hash]
+	if !ok {
+		return []store.Version{}, 0, nil
+	}
+	document := element.Value.(*versionedDocument)
+	versions = make([]store.Version, len(document.versions))
+	copy(versions, document.versions)
+	return versions, document.pinned, nil
+}
+
+func (m *memoryVersions) PinVersion(hash string, version int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if element, ok := m.documents[hash]; ok {
+		element.Value.(*versionedDocument).pinned = version
+	}
+	return nil
+}
+
+func (m *memoryVersions) RemoveAllVersions() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.documents = map[string]*list.Element{}
+	m.lru.Init()
+	m.bytes = 0
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//-----------------------------------------------------------------------------
+
+// GetVersions returns the version history of a document, oldest first
+func (c *Cache) GetVersions(id, dimension, workspace string) ([]Version, error) {
+	return c.history.get(store.GetHash(id, dimension, workspace))
+}
+
+// GetVersion returns a specific version of a document
+func (c *Cache) GetVersion(id, dimension, workspace string, version int) (Version, error) {
+	return c.history.getVersion(store.GetHash(id, dimension, workspace), version)
+}
+
+// Rollback will replace a cached document with a previous version
+// the version stays pinned until the next successful invalidation
+func (c *Cache) Rollback(id, dimension, workspace string, version int) (item store.CacheItem, err error) {
+	hash := store.GetHash(id, dimension, workspace)
+
+	start := time.Now()
+	v, errVersion := c.history.getVersion(hash, version)
+	if errVersion != nil {
+		err = errVersion
+		return
+	}
+
+	validUntil := store.ValidUntilForever
+	if cached, errGet := c.peek(hash); errGet == nil {
+		validUntil = cached.ValidUntil
+	}
+
+	item = store.NewCacheItem(id, dimension, workspace, v.HTML, v.Dependencies, validUntil)
+	item.Etag = v.Etag
+	if err = c.store.Upsert(item); err != nil {
+		return
+	}
+	if err = c.history.pin(hash, version); err != nil {
+		return
+	}
+
+	c.log.WithFields(logrus.Fields{
+		"id":        id,
+		"dimension": dimension,
+		"workspace": workspace,
+		"version":   version,
+	}).WithDuration(start).Info("content cache rolled back")
+
+	// notify observer
+	c.observer.Notify(InvalidationResponse{
+		Item:     item,
+		Duration: time.Since(start),
+	})
+	return
+}
@@ -0,0 +1,43 @@
+package content
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryVersions(t *testing.T) {
+	m := newMemoryVersions(25)
+	add := func(hash string, html string) {
+		assert.NoError(t, m.AddVersion(hash, store.Version{Etag: html, HTML: html}, 2))
+	}
+
+	add("a", strings.Repeat("a", 10))
+	add("b", strings.Repeat("b", 10))
+	assert.NoError(t, m.PinVersion("a", 1))
+	versions, pinned, err := m.GetVersions("a")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+	assert.Equal(t, 1, pinned)
+
+	// least recently changed documents are dropped to stay within the limit
+	add("c", strings.Repeat("c", 10))
+	versions, _, _ = m.GetVersions("a")
+	assert.Empty(t, versions)
+	versions, _, _ = m.GetVersions("b")
+	assert.Len(t, versions, 1)
+	assert.Equal(t, 20, m.bytes)
+
+	// the latest document is kept, even if it exceeds the limit on its own
+	add("d", strings.Repeat("d", 30))
+	assert.Equal(t, 1, m.lru.Len())
+	versions, _, _ = m.GetVersions("d")
+	assert.Len(t, versions, 1)
+
+	assert.NoError(t, m.RemoveAllVersions())
+	assert.Equal(t, 0, m.bytes)
+	versions, _, _ = m.GetVersions("d")
+	assert.Empty(t, versions)
+}
@@ -0,0 +1,78 @@
+package content_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store/memory"
+	"github.com/foomo/neosproxy/client/cms"
+	"github.com/foomo/neosproxy/logging"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLoader struct {
+	html string
+}
+
+func (l *testLoader) GetContent(id, dimension, workspace string, ctx context.Context) (cms.Content, error) {
+	return cms.Content{HTML: l.html}, nil
+}
+
+type testObserver struct{}
+
+func (o testObserver) Notify(response content.InvalidationResponse) {}
+
+func TestHistory(t *testing.T) {
+	loader := &testLoader{}
+	c := content.New(0, 2, memory.NewCacheStore(), loader, testObserver{}, logging.GetDefaultLogEntry())
+	getVersions := func() []content.Version {
+		versions, errVersions := c.GetVersions("1", "de", "live")
+		assert.NoError(t, errVersions)
+		return versions
+	}
+
+	for _, html := range []string{"<h1>1</h1>", "<h1>2</h1>", "<h1>2</h1>", "<h1>3</h1>"} {
+		loader.html = html
+		_, errLoad := c.Load("1", "de", "live")
+		assert.NoError(t, errLoad)
+	}
+
+	// oldest version dropped, unchanged content is not a new version
+	versions := getVersions()
+	assert.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[0].Version)
+	assert.Equal(t, "<h1>2</h1>", versions[0].HTML)
+	assert.Equal(t, 3, versions[1].Version)
+
+	_, errVersion := c.GetVersion("1", "de", "live", 1)
+	assert.Equal(t, content.ErrorVersionNotFound, errVersion)
+	_, errRollback := c.Rollback("1", "de", "live", 1)
+	assert.Equal(t, content.ErrorVersionNotFound, errRollback)
+
+	// rollback pins the version
+	item, errRollback := c.Rollback("1", "de", "live", 2)
+	assert.NoError(t, errRollback)
+	assert.Equal(t, versions[0].Etag, item.Etag)
+	cached, errGet := c.Get("1", "de", "live")
+	assert.NoError(t, errGet)
+	assert.Equal(t, "<h1>2</h1>", cached.HTML)
+	assert.True(t, getVersions()[0].Pinned)
+
+	// next invalidation releases the pin
+	loader.html = "<h1>4</h1>"
+	_, errLoad := c.Load("1", "de", "live")
+	assert.NoError(t, errLoad)
+	versions = getVersions()
+	assert.Equal(t, 4, versions[1].Version)
+	for _, version := range versions {
+		assert.False(t, version.Pinned)
+	}
+
+	// history disabled
+	c = content.New(0, -1, memory.NewCacheStore(), loader, testObserver{}, logging.GetDefaultLogEntry())
+	_, errLoad = c.Load("1", "de", "live")
+	assert.NoError(t, errLoad)
+	assert.Empty(t, getVersions())
+}
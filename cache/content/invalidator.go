@@ -11,6 +11,9 @@ import (
 
 // RemoveAll will reset whole cache by dropping all items
 func (c *Cache) RemoveAll() (err error) {
+	if err = c.history.removeAll(); err != nil {
+		return
+	}
 	return c.store.RemoveAll()
 }
 
@@ -43,7 +46,7 @@ func (c *Cache) Invalidate(id, dimension, workspace string) {
 }
 
 // Load will immediately load content from NEOS and persist it as a cache item
-// no retry if it fails
+// no retry if it fails, the item will be returned even if persisting it failed
 func (c *Cache) Load(id, dimension, workspace string) (item store.CacheItem, err error) {
 
 	groupName := strings.Join([]string{"invalidate", id, dimension, workspace}, "-")
@@ -56,6 +59,12 @@ func (c *Cache) Load(id, dimension, workspace string) (item store.CacheItem, err
 		})
 	})
 
+	// serve fresh content even if it could not be written to the store
+	if errThrottled == ErrorWriteFailed {
+		item = itemInterfaced.(store.CacheItem)
+		return
+	}
+
 	if errThrottled != nil {
 		err = errThrottled
 		return
@@ -81,15 +90,14 @@ func (c *Cache) invalidate(req InvalidationRequest) (item store.CacheItem, err e
 	defer cancel()
 
 	// load item
-	cmsContent, errGetContent := c.loader.GetContent(req.ID, req.Dimension, req.Workspace, ctx)
-	if errGetContent != nil {
-		err = errGetContent
+	item, err = c.fetch(ctx, req.ID, req.Dimension, req.Workspace)
+	if err != nil {
 		return
 	}
 
 	// update cache dependencies
-	if len(cmsContent.CacheDependencies) > 0 {
-		for _, targetID := range cmsContent.CacheDependencies {
+	if len(item.Dependencies) > 0 {
+		for _, targetID := range item.Dependencies {
 			c.cacheDependencies.Set(req.ID, targetID, req.Dimension, req.Workspace)
 		}
 	}
@@ -102,15 +110,25 @@ func (c *Cache) invalidate(req InvalidationRequest) (item store.CacheItem, err e
 		}
 	}
 
-	// prepare cache item
-	item = store.NewCacheItem(req.ID, req.Dimension, req.Workspace, cmsContent.HTML, cmsContent.CacheDependencies, c.validUntil(cmsContent.ValidUntil))
+	// keep the replaced version in history
+	if cached, errGet := c.peek(item.Hash); errGet == nil {
+		if errHistory := c.history.seed(cached); errHistory != nil {
+			c.log.WithError(errHistory).WithField("hash", item.Hash).Warn("failed adding replaced content to version history")
+		}
+	}
 
 	// write item to cache
+	// in degraded mode the fresh item will be returned anyway
 	errUpsert := c.store.Upsert(item)
 	if errUpsert != nil {
-		err = errUpsert
+		c.writeFailed(errUpsert)
+		err = ErrorWriteFailed
 		return
 	}
+	c.writeSucceeded()
+	if errHistory := c.history.add(item); errHistory != nil {
+		c.log.WithError(errHistory).WithField("hash", item.Hash).Warn("failed adding content to version history")
+	}
 
 	// logging
 	c.log.WithFields(logrus.Fields{
@@ -131,6 +149,23 @@ func (c *Cache) invalidate(req InvalidationRequest) (item store.CacheItem, err e
 	return
 }
 
+// Fetch will load the current rendering of a document from NEOS without caching it
+func (c *Cache) Fetch(id, dimension, workspace string) (item store.CacheItem, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return c.fetch(ctx, id, dimension, workspace)
+}
+
+func (c *Cache) fetch(ctx context.Context, id, dimension, workspace string) (item store.CacheItem, err error) {
+	cmsContent, errGetContent := c.loader.GetContent(id, dimension, workspace, ctx)
+	if errGetContent != nil {
+		err = errGetContent
+		return
+	}
+	item = store.NewCacheItem(id, dimension, workspace, cmsContent.HTML, cmsContent.CacheDependencies, c.validUntil(cmsContent.ValidUntil))
+	return
+}
+
 func (c *Cache) validUntil(validUntil int64) time.Time {
 
 	now := time.Now()
@@ -0,0 +1,36 @@
+package content
+
+import (
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content/store"
+)
+
+// revalidationPageSize of cached items read at once
+const revalidationPageSize = 500
+
+// Revalidate queues an invalidation for every item whose lifetime expired, items cached forever are skipped
+func (c *Cache) Revalidate() (count int, err error) {
+	start := time.Now()
+	query := store.Query{Limit: revalidationPageSize}
+	for {
+		infos, errList := c.List(query)
+		if errList != nil {
+			err = errList
+			return
+		}
+		for _, info := range infos {
+			if info.ValidUntil.After(store.ValidUntilForever) && info.ValidUntil.Before(start) {
+				c.Invalidate(info.ID, info.Dimension, info.Workspace)
+				count++
+			}
+		}
+		if len(infos) < query.Limit {
+			break
+		}
+		query.After = infos[len(infos)-1].Hash
+	}
+
+	c.log.WithDuration(start).WithField("count", count).Info("content cache revalidation queued expired items")
+	return
+}
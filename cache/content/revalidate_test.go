@@ -0,0 +1,40 @@
+package content_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/content/store/memory"
+	"github.com/foomo/neosproxy/logging"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevalidate(t *testing.T) {
+	cacheStore := memory.NewCacheStore()
+	assert.NoError(t, cacheStore.Upsert(store.NewCacheItem("expired", "de", "live", "<h1>old</h1>", nil, time.Now().Add(-time.Minute))))
+	assert.NoError(t, cacheStore.Upsert(store.NewCacheItem("valid", "de", "live", "<h1>valid</h1>", nil, time.Now().Add(time.Hour))))
+	assert.NoError(t, cacheStore.Upsert(store.NewCacheItem("forever", "de", "live", "<h1>forever</h1>", nil, store.ValidUntilForever)))
+
+	loader := &testLoader{html: "<h1>new</h1>"}
+	c := content.New(time.Hour, 0, cacheStore, loader, testObserver{}, logging.GetDefaultLogEntry())
+
+	count, errRevalidate := c.Revalidate()
+	assert.NoError(t, errRevalidate)
+	assert.Equal(t, 1, count)
+
+	// workers reload the expired item
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if item, errGet := c.Get("expired", "de", "live"); errGet == nil && item.HTML == "<h1>new</h1>" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	item, errGet := c.Get("expired", "de", "live")
+	assert.NoError(t, errGet)
+	assert.Equal(t, "<h1>new</h1>", item.HTML)
+	assert.True(t, item.ValidUntil.After(time.Now()))
+}
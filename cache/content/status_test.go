@@ -0,0 +1,51 @@
+package content_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/content/store/memory"
+	"github.com/foomo/neosproxy/logging"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingStore struct {
+	store.CacheStore
+	err error
+}
+
+func (s *failingStore) Upsert(item store.CacheItem) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.CacheStore.Upsert(item)
+}
+
+func TestDegraded(t *testing.T) {
+	s := &failingStore{CacheStore: memory.NewCacheStore(), err: errors.New("no space left on device")}
+	c := content.New(0, 0, s, &testLoader{html: "<h1>fresh</h1>"}, testObserver{}, logging.GetDefaultLogEntry())
+
+	// fresh content is served, but not cached
+	item, errLoad := c.Load("1", "de", "live")
+	assert.NoError(t, errLoad)
+	assert.Equal(t, "<h1>fresh</h1>", item.HTML)
+	_, errGet := c.Get("1", "de", "live")
+	assert.Equal(t, content.ErrorNotFound, errGet)
+
+	status := c.Status()
+	assert.True(t, status.Degraded)
+	assert.Equal(t, uint64(1), status.WriteErrors)
+	assert.Equal(t, "no space left on device", status.LastWriteError)
+
+	// recovered
+	s.err = nil
+	_, errLoad = c.Load("1", "de", "live")
+	assert.NoError(t, errLoad)
+	status = c.Status()
+	assert.False(t, status.Degraded)
+	assert.Equal(t, uint64(1), status.WriteErrors)
+	assert.Equal(t, 1, status.Items)
+}
@@ -0,0 +1,363 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/logging"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+//------------------------------------------------------------------
+// ~ CONSTANTS / VARS
+//------------------------------------------------------------------
+
+var _ store.Iterator = &boltCacheStore{}
+
+var (
+	// items/<workspace>/<dimension>/<id> => serialized cache item
+	bucketItems = []byte("items")
+	// etags/<workspace>/<hash> => etag
+	bucketEtags = []byte("etags")
+	// dependencies/<hash> => serialized cache dependencies (incl. item location)
+	bucketDependencies = []byte("dependencies")
+	// bucketDefault replaces empty workspace or dimension names, bbolt requires a bucket name
+	bucketDefault = []byte("_")
+)
+
+// ErrorPathRequired is returned if the store is created without a database file
+var ErrorPathRequired = errors.New("bolt database path required")
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// boltCacheStore implements a cache store on top of an embedded bbolt database
+type boltCacheStore struct {
+	db *bbolt.DB
+	l  logging.Entry
+}
+
+//------------------------------------------------------------------
+// ~ CONSTRUCTOR
+//------------------------------------------------------------------
+
+func init() {
+	store.Register("bolt", func(options map[string]string) (store.CacheStore, error) {
+		directory := store.Option(options, store.OptionDirectory, "")
+		return NewCacheStore(store.Option(options, store.OptionPath, filepath.Join(directory, "cache.db")))
+	})
+}
+
+// NewCacheStore creates a new bbolt cache store, the database file will be created if missing
+func NewCacheStore(filename string) (store.CacheStore, error) {
+
+	l := logging.GetDefaultLogEntry().WithField("cache", "boltcache")
+
+	if filename == "" {
+		return nil, ErrorPathRequired
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+
+	// do not block forever in case another process holds the database lock
+	db, errOpen := bbolt.Open(filename, 0644, &bbolt.Options{Timeout: 5 * time.Second})
+	if errOpen != nil {
+		return nil, errOpen
+	}
+
+	s := &boltCacheStore{
+		db: db,
+		l:  l,
+	}
+
+	if errInit := s.db.Update(s.createBuckets); errInit != nil {
+		db.Close()
+		return nil, errInit
+	}
+
+	return s, nil
+}
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+func (s *boltCacheStore) Upsert(item store.CacheItem) (e error) {
+
+	// validate etag
+	if item.Etag == "" {
+		item.Etag = item.GetEtag()
+	}
+
+	// serialize
+	itemBytes, errMarshalItem := json.Marshal(item)
+	if errMarshalItem != nil {
+		return errMarshalItem
+	}
+	dependencyBytes, errMarshalDependencies := json.Marshal(store.CacheDependencies{
+		ID:           item.ID,
+		Dimension:    item.Dimension,
+		Workspace:    item.Workspace,
+		Dependencies: item.Dependencies,
+	})
+	if errMarshalDependencies != nil {
+		return errMarshalDependencies
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		// an item may have moved => remove old location first
+		if errRemove := s.remove(tx, item.Hash); errRemove != nil {
+			return errRemove
+		}
+
+		items, errItems := itemBucket(tx, item.Workspace, item.Dimension, true)
+		if errItems != nil {
+			return errItems
+		}
+		if errPut := items.Put([]byte(item.ID), itemBytes); errPut != nil {
+			return errPut
+		}
+
+		etags, errEtags := etagBucket(tx, item.Workspace, true)
+		if errEtags != nil {
+			return errEtags
+		}
+		if errPut := etags.Put([]byte(item.Hash), []byte(item.Etag)); errPut != nil {
+			return errPut
+		}
+
+		return tx.Bucket(bucketDependencies).Put([]byte(item.Hash), dependencyBytes)
+	})
+}
+
+func (s *boltCacheStore) Get(hash string) (item store.CacheItem, e error) {
+	e = s.db.View(func(tx *bbolt.Tx) error {
+		location, errLocation := lookup(tx, hash)
+		if errLocation != nil {
+			return errLocation
+		}
+
+		items, _ := itemBucket(tx, location.Workspace, location.Dimension, false)
+		if items == nil {
+			return content.ErrorNotFound
+		}
+		data := items.Get([]byte(location.ID))
+		if data == nil {
+			return content.ErrorNotFound
+		}
+
+		return json.Unmarshal(data, &item)
+	})
+	return
+}
+
+func (s *boltCacheStore) GetAll() (items []store.CacheItem, e error) {
+	items = []store.CacheItem{}
+	e = s.ForEach(func(item store.CacheItem) error {
+		items = append(items, item)
+		return nil
+	})
+	return
+}
+
+// ForEach streams all cached items within a read transaction, fn must not write to the same store
+func (s *boltCacheStore) ForEach(fn func(item store.CacheItem) error) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return forEachItemBucket(tx, func(b *bbolt.Bucket) error {
+			return b.ForEach(func(k, v []byte) error {
+				item := store.CacheItem{}
+				if errUnmarshal := json.Unmarshal(v, &item); errUnmarshal != nil {
+					return errUnmarshal
+				}
+				return fn(item)
+			})
+		})
+	})
+}
+
+func (s *boltCacheStore) GetEtag(hash string) (etag string, e error) {
+	e = s.db.View(func(tx *bbolt.Tx) error {
+		location, errLocation := lookup(tx, hash)
+		if errLocation != nil {
+			return errLocation
+		}
+
+		etags, _ := etagBucket(tx, location.Workspace, false)
+		if etags == nil {
+			return content.ErrorNotFound
+		}
+		value := etags.Get([]byte(hash))
+		if value == nil {
+			return content.ErrorNotFound
+		}
+
+		etag = string(value)
+		return nil
+	})
+	return
+}
+
+func (s *boltCacheStore) GetAllEtags(workspace string) (etags map[string]string) {
+	etags = make(map[string]string)
+	errView := s.db.View(func(tx *bbolt.Tx) error {
+		b, _ := etagBucket(tx, workspace, false)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			etags[string(k)] = string(v)
+			return nil
+		})
+	})
+	if errView != nil {
+		s.l.WithError(errView).WithField(logging.FieldWorkspace, workspace).Error("failed reading etags")
+	}
+	return
+}
+
+func (s *boltCacheStore) GetAllCacheDependencies() ([]store.CacheDependencies, error) {
+	start := time.Now()
+	l := s.l.WithField(logging.FieldFunction, "GetAllCacheDependencies")
+
+	dependencies := []store.CacheDependencies{}
+	errView := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketDependencies).ForEach(func(k, v []byte) error {
+			dependency := store.CacheDependencies{}
+			if errUnmarshal := json.Unmarshal(v, &dependency); errUnmarshal != nil {
+				l.WithError(errUnmarshal).Warn("could not load cache dependencies")
+				return nil
+			}
+			dependencies = append(dependencies, dependency)
+			return nil
+		})
+	})
+	if errView != nil {
+		return nil, errView
+	}
+
+	l.WithField("len", len(dependencies)).WithDuration(start).Debug("all cache dependencies loaded")
+	return dependencies, nil
+}
+
+func (s *boltCacheStore) Count() (count int, e error) {
+	e = s.db.View(func(tx *bbolt.Tx) error {
+		count = tx.Bucket(bucketDependencies).Stats().KeyN
+		return nil
+	})
+	return
+}
+
+func (s *boltCacheStore) Remove(hash string) (e error) {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return s.remove(tx, hash)
+	})
+}
+
+func (s *boltCacheStore) RemoveAll() (e error) {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{bucketItems, bucketEtags, bucketDependencies} {
+			if errDelete := tx.DeleteBucket(name); errDelete != nil && errDelete != bbolt.ErrBucketNotFound {
+				return errDelete
+			}
+		}
+		return s.createBuckets(tx)
+	})
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+func (s *boltCacheStore) createBuckets(tx *bbolt.Tx) error {
+	for _, name := range [][]byte{bucketItems, bucketEtags, bucketDependencies} {
+		if _, errCreate := tx.CreateBucketIfNotExists(name); errCreate != nil {
+			return errCreate
+		}
+	}
+	return nil
+}
+
+// remove an item and all its index entries, missing items will be ignored
+func (s *boltCacheStore) remove(tx *bbolt.Tx, hash string) error {
+	location, errLocation := lookup(tx, hash)
+	if errLocation == content.ErrorNotFound {
+		return nil
+	} else if errLocation != nil {
+		return errLocation
+	}
+
+	if items, _ := itemBucket(tx, location.Workspace, location.Dimension, false); items != nil {
+		if errDelete := items.Delete([]byte(location.ID)); errDelete != nil {
+			return errDelete
+		}
+	}
+
+	if etags, _ := etagBucket(tx, location.Workspace, false); etags != nil {
+		if errDelete := etags.Delete([]byte(hash)); errDelete != nil {
+			return errDelete
+		}
+	}
+
+	return tx.Bucket(bucketDependencies).Delete([]byte(hash))
+}
+
+// lookup the location of an item by its hash using the dependency index
+func lookup(tx *bbolt.Tx, hash string) (location store.CacheDependencies, e error) {
+	data := tx.Bucket(bucketDependencies).Get([]byte(hash))
+	if data == nil {
+		e = content.ErrorNotFound
+		return
+	}
+	e = json.Unmarshal(data, &location)
+	return
+}
+
+func itemBucket(tx *bbolt.Tx, workspace, dimension string, create bool) (*bbolt.Bucket, error) {
+	workspaceBucket, errWorkspace := nestedBucket(tx.Bucket(bucketItems), workspace, create)
+	if workspaceBucket == nil {
+		return nil, errWorkspace
+	}
+	return nestedBucket(workspaceBucket, dimension, create)
+}
+
+func etagBucket(tx *bbolt.Tx, workspace string, create bool) (*bbolt.Bucket, error) {
+	return nestedBucket(tx.Bucket(bucketEtags), workspace, create)
+}
+
+func nestedBucket(parent *bbolt.Bucket, name string, create bool) (*bbolt.Bucket, error) {
+	key := []byte(name)
+	if name == "" {
+		key = bucketDefault
+	}
+	if create {
+		return parent.CreateBucketIfNotExists(key)
+	}
+	return parent.Bucket(key), nil
+}
+
+// forEachItemBucket calls fn for every workspace/dimension bucket
+func forEachItemBucket(tx *bbolt.Tx, fn func(b *bbolt.Bucket) error) error {
+	items := tx.Bucket(bucketItems)
+	return items.ForEach(func(workspace, v []byte) error {
+		workspaceBucket := items.Bucket(workspace)
+		if workspaceBucket == nil {
+			return nil
+		}
+		return workspaceBucket.ForEach(func(dimension, v []byte) error {
+			dimensionBucket := workspaceBucket.Bucket(dimension)
+			if dimensionBucket == nil {
+				return nil
+			}
+			return fn(dimensionBucket)
+		})
+	})
+}
@@ -0,0 +1,100 @@
+package bolt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/content/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, errStore := NewCacheStore(filepath.Join(dir, "cache.db"))
+	assert.NoError(t, errStore)
+
+	count, countErr := s.Count()
+	assert.NoError(t, countErr)
+	assert.Equal(t, 0, count)
+
+	dependencies := []string{"foo", "bar"}
+
+	item := store.NewCacheItem("1234", "de", "live", "<h1>Test</h1>", dependencies, time.Now())
+	assert.NoError(t, s.Upsert(item))
+	assert.NoError(t, s.Upsert(store.NewCacheItem("1234", "de", "live-preview", "<h1>Preview</h1>", nil, time.Now())))
+
+	count, countErr = s.Count()
+	assert.NoError(t, countErr)
+	assert.Equal(t, 2, count)
+
+	itemCached, errGet := s.Get(item.Hash)
+	assert.NoError(t, errGet)
+	assert.Equal(t, item.HTML, itemCached.HTML)
+	assert.Equal(t, dependencies, itemCached.Dependencies)
+
+	etag, errEtag := s.GetEtag(item.Hash)
+	assert.NoError(t, errEtag)
+	assert.Equal(t, item.Etag, etag)
+
+	etags := s.GetAllEtags("live")
+	assert.Len(t, etags, 1)
+	assert.Equal(t, item.Etag, etags[item.Hash])
+
+	cacheDependencies, errDependencies := s.GetAllCacheDependencies()
+	assert.NoError(t, errDependencies)
+	assert.Len(t, cacheDependencies, 2)
+
+	assert.NoError(t, s.Remove(item.Hash))
+	_, errGet = s.Get(item.Hash)
+	assert.Equal(t, content.ErrorNotFound, errGet)
+	assert.Empty(t, s.GetAllEtags("live"))
+
+	assert.NoError(t, s.RemoveAll())
+	countAfterRemove, errCountAfterRemove := s.Count()
+	assert.NoError(t, errCountAfterRemove)
+	assert.Equal(t, 0, countAfterRemove)
+}
+
+func TestEmptyBucketNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, errStore := NewCacheStore("")
+	assert.Equal(t, ErrorPathRequired, errStore)
+
+	s, errStore := NewCacheStore(filepath.Join(dir, "cache.db"))
+	assert.NoError(t, errStore)
+
+	// items without workspace or dimension are stored in the default bucket
+	item := store.NewCacheItem("1234", "", "", "<h1>Test</h1>", nil, time.Now())
+	assert.NoError(t, s.Upsert(item))
+	itemCached, errGet := s.Get(item.Hash)
+	assert.NoError(t, errGet)
+	assert.Equal(t, item.HTML, itemCached.HTML)
+	assert.Equal(t, item.Etag, s.GetAllEtags("")[item.Hash])
+
+	assert.NoError(t, s.Remove(item.Hash))
+	_, errGet = s.Get(item.Hash)
+	assert.Equal(t, content.ErrorNotFound, errGet)
+}
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	storetest.Run(t, func(t *testing.T) store.CacheStore {
+		s, errStore := NewCacheStore(filepath.Join(dir, t.Name()+".db"))
+		assert.NoError(t, errStore)
+		return s
+	})
+}
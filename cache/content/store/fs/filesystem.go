@@ -2,12 +2,16 @@ package fs
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/cloudfoundry/bytefmt"
 	"github.com/foomo/neosproxy/cache/content"
 	"github.com/foomo/neosproxy/cache/content/store"
 	"github.com/foomo/neosproxy/logging"
@@ -25,16 +29,49 @@ type fsCacheStore struct {
 	rw   map[string]*sync.RWMutex
 	l    logging.Entry
 
-	lockEtags sync.RWMutex
-	etags     map[string]string
+	lockEtags  sync.RWMutex
+	etags      map[string]string // hash => etag
+	workspaces map[string]string // hash => workspace
+
+	lockUsage sync.Mutex
+	lockEvict sync.Mutex
+	quota     uint64               // size limit of all cached items, 0 === unlimited
+	size      uint64               // size of all cached items
+	sizes     map[string]uint64    // hash => file size
+	served    map[string]time.Time // hash => last served, least recently served items are evicted first
+	evictions uint64
 }
 
 //------------------------------------------------------------------
 // ~ CONSTRUCTOR
 //------------------------------------------------------------------
 
+func init() {
+	store.Register("fs", func(options map[string]string) (store.CacheStore, error) {
+		directory := store.Option(options, store.OptionDirectory, "")
+		if directory == "" {
+			return nil, errors.New("fs: option directory required")
+		}
+		quota := uint64(0)
+		if value := store.Option(options, store.OptionQuota, ""); value != "" {
+			var errQuota error
+			quota, errQuota = bytefmt.ToBytes(value)
+			if errQuota != nil {
+				return nil, errors.New("fs: invalid option quota: " + errQuota.Error())
+			}
+		}
+		return NewCacheStoreWithQuota(directory, quota), nil
+	})
+}
+
 // NewCacheStore creates a new filesystem cache store
 func NewCacheStore(cacheDir string) store.CacheStore {
+	return NewCacheStoreWithQuota(cacheDir, 0)
+}
+
+// NewCacheStoreWithQuota creates a new filesystem cache store limited to quota bytes
+// least recently served items will be evicted once the quota is exceeded
+func NewCacheStoreWithQuota(cacheDir string, quota uint64) store.CacheStore {
 
 	l := logging.GetDefaultLogEntry().WithField("cache", "fscache")
 
@@ -50,10 +87,17 @@ func NewCacheStore(cacheDir string) store.CacheStore {
 		lock: sync.Mutex{},
 		rw:   make(map[string]*sync.RWMutex),
 
-		lockEtags: sync.RWMutex{},
-		etags:     make(map[string]string),
+		lockEtags:  sync.RWMutex{},
+		etags:      make(map[string]string),
+		workspaces: make(map[string]string),
+
+		quota:  quota,
+		sizes:  make(map[string]uint64),
+		served: make(map[string]time.Time),
 	}
 
+	f.migrateKeys()
+
 	go f.initEtagCache()
 
 	return f
@@ -91,7 +135,11 @@ func (f *fsCacheStore) Upsert(item store.CacheItem) (e error) {
 	f.Unlock(key)
 
 	// update etag
-	f.upsertEtag(item.Hash, item.Etag)
+	f.upsertEtag(item)
+
+	// update usage, a new item counts as served, a rewritten one keeps its last serve
+	f.trackUsage(item.Hash, uint64(len(bytes)), time.Now())
+	f.evict(item.Hash)
 
 	return nil
 }
@@ -110,12 +158,8 @@ func (f *fsCacheStore) GetAllCacheDependencies() ([]store.CacheDependencies, err
 	counter := 0
 	for _, file := range files {
 		if !file.IsDir() {
-			filename := file.Name()
-			index := strings.Index(filename, ".")
-			if index >= 0 {
-				filename = filename[0:index]
-			}
-			item, errGet := f.Get(filename)
+			filename := strings.TrimSuffix(file.Name(), ".json")
+			item, errGet := f.read(filename)
 			if errGet != nil {
 				l.WithError(errGet).Warn("could not load cache item")
 				continue
@@ -138,7 +182,7 @@ func (f *fsCacheStore) GetAllEtags(workspace string) (etags map[string]string) {
 	f.lockEtags.RLock()
 	etags = make(map[string]string)
 	for hash, etag := range f.etags {
-		if !strings.HasPrefix(hash, workspace) {
+		if f.workspaces[hash] != workspace {
 			continue
 		}
 		etags[hash] = etag
@@ -156,19 +200,44 @@ func (f *fsCacheStore) GetEtag(hash string) (etag string, e error) {
 	}
 	f.lockEtags.RUnlock()
 
-	item, errGet := f.Get(hash)
+	item, errGet := f.read(hash)
 	if errGet != nil {
 		e = errGet
 		return
 	}
 
 	etag = item.GetEtag()
-	f.upsertEtag(hash, etag)
+	f.upsertEtag(item)
 
 	return
 }
 
 func (f *fsCacheStore) Get(hash string) (item store.CacheItem, e error) {
+	item, e = f.read(hash)
+	if e == nil {
+		f.touch(hash)
+	}
+	return
+}
+
+// Peek reads an item without marking it as served, for lookups which do not serve it
+func (f *fsCacheStore) Peek(hash string) (item store.CacheItem, e error) {
+	return f.read(hash)
+}
+
+// Usage returns the size of all cached items
+func (f *fsCacheStore) Usage() store.Usage {
+	f.lockUsage.Lock()
+	defer f.lockUsage.Unlock()
+	return store.Usage{
+		Items:     len(f.sizes),
+		Bytes:     f.size,
+		Quota:     f.quota,
+		Evictions: f.evictions,
+	}
+}
+
+func (f *fsCacheStore) read(hash string) (item store.CacheItem, e error) {
 	key := f.getKey(hash)
 	cacheFile, _ := f.RLock(key)
 
@@ -208,12 +277,8 @@ func (f *fsCacheStore) GetAll() (items []store.CacheItem, e error) {
 	items = []store.CacheItem{}
 	for _, file := range files {
 		if !file.IsDir() {
-			filename := file.Name()
-			index := strings.Index(filename, ".")
-			if index >= 0 {
-				filename = filename[0:index]
-			}
-			item, errGet := f.Get(filename)
+			filename := strings.TrimSuffix(file.Name(), ".json")
+			item, errGet := f.read(filename)
 			if errGet != nil {
 				e = errGet
 				return
@@ -225,6 +290,31 @@ func (f *fsCacheStore) GetAll() (items []store.CacheItem, e error) {
 	return
 }
 
+// ForEach streams all cached items, unreadable items will be skipped
+func (f *fsCacheStore) ForEach(fn func(item store.CacheItem) error) error {
+	l := f.l.WithField(logging.FieldFunction, "ForEach")
+	files, errReadDir := ioutil.ReadDir(f.CacheDir)
+	if errReadDir != nil {
+		return errReadDir
+	}
+
+	for _, file := range files {
+		if !file.IsDir() {
+			filename := strings.TrimSuffix(file.Name(), ".json")
+			item, errGet := f.read(filename)
+			if errGet != nil {
+				l.WithError(errGet).Warn("could not load cache item")
+				continue
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func (f *fsCacheStore) Count() (int, error) {
 	i := 0
 	files, err := ioutil.ReadDir(f.CacheDir)
@@ -245,15 +335,18 @@ func (f *fsCacheStore) Remove(hash string) (e error) {
 	defer f.Unlock(key)
 
 	errRemove := os.Remove(cacheFile)
-	if errRemove != nil {
+	if errRemove != nil && !os.IsNotExist(errRemove) {
 		e = errRemove
 		return
 	}
 
 	f.lockEtags.Lock()
 	delete(f.etags, hash)
+	delete(f.workspaces, hash)
 	f.lockEtags.Unlock()
 
+	f.untrackUsage(hash)
+
 	return nil
 }
 
@@ -273,8 +366,15 @@ func (f *fsCacheStore) RemoveAll() (e error) {
 
 	f.lockEtags.Lock()
 	f.etags = make(map[string]string)
+	f.workspaces = make(map[string]string)
 	f.lockEtags.Unlock()
 
+	f.lockUsage.Lock()
+	f.size = 0
+	f.sizes = make(map[string]uint64)
+	f.served = make(map[string]time.Time)
+	f.lockUsage.Unlock()
+
 	errCreateCache := f.createCacheDir()
 	if errCreateCache != nil {
 		f.l.WithError(errCreateCache).Error("unable to re-create cache directory")
@@ -288,6 +388,52 @@ func (f *fsCacheStore) RemoveAll() (e error) {
 // ~ PRIVATE METHODS
 //------------------------------------------------------------------
 
+// migrateKeys renames cache files of the legacy "<workspace>_<dimension>_<id>" format
+// whose values contained underscores or characters escaped by store.Key
+func (f *fsCacheStore) migrateKeys() {
+	start := time.Now()
+	l := f.l.WithField(logging.FieldFunction, "migrateKeys")
+	files, errReadDir := ioutil.ReadDir(f.CacheDir)
+	if errReadDir != nil {
+		l.WithError(errReadDir).Error("failed reading cache dir")
+		return
+	}
+
+	counter := 0
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		hash := strings.TrimSuffix(file.Name(), ".json")
+		if strings.Count(hash, "_") == 2 && !strings.ContainsAny(hash, "%\\") {
+			continue
+		}
+
+		item, errRead := f.read(hash)
+		if errRead != nil {
+			l.WithError(errRead).WithField("file", file.Name()).Warn("could not load cache item")
+			continue
+		}
+		key := item.Key().String()
+		if key == hash {
+			continue
+		}
+
+		item.Hash = key
+		if errUpsert := f.Upsert(item); errUpsert != nil {
+			l.WithError(errUpsert).WithField("file", file.Name()).Error("failed migrating cache item")
+			continue
+		}
+		if errRemove := os.Remove(filepath.Join(f.CacheDir, file.Name())); errRemove != nil {
+			l.WithError(errRemove).WithField("file", file.Name()).Error("failed removing migrated cache item")
+		}
+		counter++
+	}
+	if counter > 0 {
+		l.WithField("len", counter).WithDuration(start).Info("cache file names migrated")
+	}
+}
+
 func (f *fsCacheStore) initEtagCache() {
 	start := time.Now()
 	l := f.l.WithField(logging.FieldFunction, "initEtagCache")
@@ -301,31 +447,121 @@ func (f *fsCacheStore) initEtagCache() {
 	counter := 0
 	for _, file := range files {
 		if !file.IsDir() {
-			filename := file.Name()
-			index := strings.Index(filename, ".")
-			if index >= 0 {
-				filename = filename[0:index]
-			}
-			item, errGet := f.Get(filename)
+			filename := strings.TrimSuffix(file.Name(), ".json")
+			item, errGet := f.read(filename)
 			if errGet != nil {
 				l.WithError(errGet).Warn("could not load cache item")
 				continue
 			}
 			counter++
-			f.upsertEtag(item.Hash, item.GetEtag())
+			f.upsertEtag(item)
+			// last served is unknown after a restart, use the modification time instead
+			f.initUsage(item.Hash, uint64(file.Size()), file.ModTime())
 		}
 	}
 	l.WithField("len", counter).WithDuration(start).Debug("etag cache initialized")
+	f.evict("")
 
 	return
 }
 
-func (f *fsCacheStore) upsertEtag(hash, etag string) {
+func (f *fsCacheStore) upsertEtag(item store.CacheItem) {
 	f.lockEtags.Lock()
-	f.etags[hash] = etag
+	f.etags[item.Hash] = item.GetEtag()
+	f.workspaces[item.Hash] = item.Workspace
 	f.lockEtags.Unlock()
 }
 
+func (f *fsCacheStore) trackUsage(hash string, size uint64, served time.Time) {
+	f.lockUsage.Lock()
+	f.size = f.size - f.sizes[hash] + size
+	f.sizes[hash] = size
+	if _, ok := f.served[hash]; !ok {
+		f.served[hash] = served
+	}
+	f.lockUsage.Unlock()
+}
+
+// initUsage tracks an item found on disk unless it has been written in the meantime
+func (f *fsCacheStore) initUsage(hash string, size uint64, served time.Time) {
+	f.lockUsage.Lock()
+	if _, ok := f.sizes[hash]; !ok {
+		f.size += size
+		f.sizes[hash] = size
+		f.served[hash] = served
+	}
+	f.lockUsage.Unlock()
+}
+
+func (f *fsCacheStore) untrackUsage(hash string) {
+	f.lockUsage.Lock()
+	f.size -= f.sizes[hash]
+	delete(f.sizes, hash)
+	delete(f.served, hash)
+	f.lockUsage.Unlock()
+}
+
+func (f *fsCacheStore) touch(hash string) {
+	f.lockUsage.Lock()
+	if _, ok := f.sizes[hash]; ok {
+		f.served[hash] = time.Now()
+	}
+	f.lockUsage.Unlock()
+}
+
+// evict least recently served items until 90% of the quota are used, item keep will not be evicted
+func (f *fsCacheStore) evict(keep string) {
+	if f.quota == 0 {
+		return
+	}
+
+	f.lockEvict.Lock()
+	defer f.lockEvict.Unlock()
+
+	f.lockUsage.Lock()
+	if f.size <= f.quota {
+		f.lockUsage.Unlock()
+		return
+	}
+	target := f.quota / 10 * 9
+	excess := f.size - target
+	hashes := make([]string, 0, len(f.served))
+	for hash := range f.served {
+		if hash != keep {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return f.served[hashes[i]].Before(f.served[hashes[j]])
+	})
+	f.lockUsage.Unlock()
+
+	start := time.Now()
+	evicted := 0
+	freed := uint64(0)
+	for _, hash := range hashes {
+		if freed >= excess {
+			break
+		}
+		f.lockUsage.Lock()
+		size := f.sizes[hash]
+		f.lockUsage.Unlock()
+
+		if errRemove := f.Remove(hash); errRemove != nil {
+			f.l.WithError(errRemove).WithField("hash", hash).Warn("failed evicting cache item")
+			continue
+		}
+		freed += size
+		evicted++
+	}
+
+	f.lockUsage.Lock()
+	f.evictions += uint64(evicted)
+	f.lockUsage.Unlock()
+
+	f.l.WithField("len", evicted).WithField("size", bytefmt.ByteSize(freed)).WithDuration(start).Info("evicted least recently served cache items")
+}
+
 func (f *fsCacheStore) getItemKey(item store.CacheItem) string {
 	return f.getKey(item.Hash)
 }
@@ -1,11 +1,19 @@
 package fs
 
 import (
-	"github.com/foomo/neosproxy/cache/content/store"
-	"github.com/stretchr/testify/assert"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/content/store/storetest"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNewCacheStore(t *testing.T) {
@@ -28,6 +36,100 @@ func TestNewCacheStore(t *testing.T) {
 
 	cachedItem, err := s.Get(hash)
 	assert.NoError(t, err)
+	item.Etag = item.GetEtag()
 	assert.Equal(t, item, cachedItem)
 
 }
+
+func TestQuota(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	html := strings.Repeat("x", 1000)
+	item := func(i int) store.CacheItem {
+		return store.NewCacheItem(fmt.Sprint(i), "de", "live", html, nil, store.ValidUntilForever)
+	}
+
+	// room for 5 items of about 1250 bytes
+	s := NewCacheStoreWithQuota(dir, 7000)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, s.Upsert(item(i)))
+		time.Sleep(time.Millisecond)
+	}
+
+	// item 0 has been served most recently
+	_, errGet := s.Get(item(0).Hash)
+	assert.NoError(t, errGet)
+
+	// item 1 has been looked up and rewritten by an invalidation, it has not been served
+	_, errPeek := s.(store.Peeker).Peek(item(1).Hash)
+	assert.NoError(t, errPeek)
+	assert.NoError(t, s.Upsert(item(1)))
+
+	usage := s.(store.UsageReporter).Usage()
+	assert.Equal(t, 5, usage.Items)
+	assert.Equal(t, uint64(7000), usage.Quota)
+	assert.Zero(t, usage.Evictions)
+
+	// exceeding the quota evicts least recently served items down to 90%
+	assert.NoError(t, s.Upsert(item(5)))
+	assert.NoError(t, s.Upsert(item(6)))
+
+	usage = s.(store.UsageReporter).Usage()
+	assert.True(t, usage.Bytes <= 6300, "bytes %d", usage.Bytes)
+	assert.NotZero(t, usage.Evictions)
+
+	_, errGet = s.Get(item(1).Hash)
+	assert.Equal(t, content.ErrorNotFound, errGet)
+	for _, i := range []int{0, 6} {
+		_, errGet = s.Get(item(i).Hash)
+		assert.NoError(t, errGet, "item %d", i)
+	}
+
+	count, errCount := s.Count()
+	assert.NoError(t, errCount)
+	assert.Equal(t, usage.Items, count)
+}
+
+func TestMigrateKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// legacy file name of a dimension containing an underscore
+	item := store.NewCacheItem("123", "de_CH", "live", "<h1>Test</h1>", nil, store.ValidUntilForever)
+	item.Hash = "live_de_CH_123"
+	bytes, errMarshal := json.Marshal(item)
+	assert.NoError(t, errMarshal)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, item.Hash+".json"), bytes, 0644))
+
+	plain := store.NewCacheItem("123", "de", "live", "<h1>Test</h1>", nil, store.ValidUntilForever)
+	bytes, errMarshal = json.Marshal(plain)
+	assert.NoError(t, errMarshal)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "live_de_123.json"), bytes, 0644))
+
+	s := NewCacheStore(dir)
+
+	migrated, errGet := s.Get(store.GetHash("123", "de_CH", "live"))
+	assert.NoError(t, errGet)
+	assert.Equal(t, "live_de%5FCH_123", migrated.Hash)
+	assert.Equal(t, "de_CH", migrated.Dimension)
+
+	_, errGet = s.Get(plain.Hash)
+	assert.NoError(t, errGet)
+
+	count, errCount := s.Count()
+	assert.NoError(t, errCount)
+	assert.Equal(t, 2, count)
+}
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	storetest.Run(t, func(t *testing.T) store.CacheStore {
+		return NewCacheStore(filepath.Join(dir, t.Name()))
+	})
+}
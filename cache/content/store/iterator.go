@@ -0,0 +1,25 @@
+package store
+
+// Iterator may be implemented by cache stores which are able to stream all items
+// without loading the whole cache into memory
+type Iterator interface {
+	ForEach(fn func(item CacheItem) error) error
+}
+
+// ForEach calls fn for every cached item, iteration stops on the first error
+func ForEach(s CacheStore, fn func(item CacheItem) error) error {
+	if iterator, ok := s.(Iterator); ok {
+		return iterator.ForEach(fn)
+	}
+
+	items, errItems := s.GetAll()
+	if errItems != nil {
+		return errItems
+	}
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
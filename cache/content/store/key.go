@@ -0,0 +1,83 @@
+package store
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// Key identifies a cached document
+type Key struct {
+	ID        string
+	Dimension string
+	Workspace string
+}
+
+//------------------------------------------------------------------
+// ~ CONSTANTS / VARS
+//------------------------------------------------------------------
+
+const keySeparator = "_"
+
+// ErrorInvalidKey error in case a hash can not be parsed into a key
+var ErrorInvalidKey = errors.New("invalid cache key")
+
+// keyEscaper escapes the separator and characters not allowed in file names
+var keyEscaper = strings.NewReplacer(
+	"%", "%25",
+	"_", "%5F",
+	"/", "%2F",
+	"\\", "%5C",
+)
+
+//------------------------------------------------------------------
+// ~ CONSTRUCTOR
+//------------------------------------------------------------------
+
+// NewKey creates a document key
+func NewKey(id, dimension, workspace string) Key {
+	return Key{
+		ID:        id,
+		Dimension: dimension,
+		Workspace: workspace,
+	}
+}
+
+// ParseKey parses a hash created by Key.String
+func ParseKey(hash string) (key Key, err error) {
+	parts := strings.Split(hash, keySeparator)
+	if len(parts) != 3 {
+		err = ErrorInvalidKey
+		return
+	}
+
+	for i, part := range parts {
+		unescaped, errUnescape := url.PathUnescape(part)
+		if errUnescape != nil {
+			err = ErrorInvalidKey
+			return
+		}
+		parts[i] = unescaped
+	}
+
+	key = NewKey(parts[2], parts[1], parts[0])
+	return
+}
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+// String encodes a key as <workspace>_<dimension>_<id>
+// values are escaped, keys of plain values are identical to the legacy format
+func (k Key) String() string {
+	return strings.Join([]string{
+		keyEscaper.Replace(k.Workspace),
+		keyEscaper.Replace(k.Dimension),
+		keyEscaper.Replace(k.ID),
+	}, keySeparator)
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	// plain values keep the legacy format
+	assert.Equal(t, "live_de_571fd1ae-c8e4-4d91-a708-d97025fb015c", GetHash("571fd1ae-c8e4-4d91-a708-d97025fb015c", "de", "live"))
+
+	for _, key := range []Key{
+		NewKey("123", "de", "live"),
+		NewKey("123", "de_CH", "live"),
+		NewKey("a_b/c%d", "en_US", "user_admin"),
+		NewKey("123", "", "live"),
+	} {
+		hash := key.String()
+		parsed, err := ParseKey(hash)
+		assert.NoError(t, err, hash)
+		assert.Equal(t, key, parsed)
+	}
+
+	assert.Equal(t, "live_de%5FCH_123", GetHash("123", "de_CH", "live"))
+	assert.NotEqual(t, GetHash("b_c", "a", "live"), GetHash("c", "a_b", "live"))
+
+	for _, hash := range []string{"", "live_de", "live_de_1_2", "live_de_%zz"} {
+		_, err := ParseKey(hash)
+		assert.Equal(t, ErrorInvalidKey, err, hash)
+	}
+}
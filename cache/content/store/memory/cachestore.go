@@ -20,6 +20,12 @@ type memoryCacheStore struct {
 // ~ CONSTRUCTOR
 //------------------------------------------------------------------
 
+func init() {
+	store.Register("memory", func(options map[string]string) (store.CacheStore, error) {
+		return NewCacheStore(), nil
+	})
+}
+
 // NewCacheStore creates a new in-memory cache store
 func NewCacheStore() store.CacheStore {
 	s := &memoryCacheStore{
@@ -37,6 +43,11 @@ func (s *memoryCacheStore) Upsert(cache store.CacheItem) (e error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	// validate etag
+	if cache.Etag == "" {
+		cache.Etag = cache.GetEtag()
+	}
+
 	s.items[cache.Hash] = cache
 	return
 }
@@ -66,6 +77,49 @@ func (s *memoryCacheStore) GetAll() (caches []store.CacheItem, e error) {
 	return
 }
 
+func (s *memoryCacheStore) GetEtag(hash string) (etag string, e error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	cache, ok := s.items[hash]
+	if !ok {
+		e = content.ErrorNotFound
+		return
+	}
+	etag = cache.GetEtag()
+	return
+}
+
+func (s *memoryCacheStore) GetAllEtags(workspace string) (etags map[string]string) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	etags = make(map[string]string)
+	for hash, cache := range s.items {
+		if cache.Workspace != workspace {
+			continue
+		}
+		etags[hash] = cache.GetEtag()
+	}
+	return
+}
+
+func (s *memoryCacheStore) GetAllCacheDependencies() ([]store.CacheDependencies, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	dependencies := make([]store.CacheDependencies, 0, len(s.items))
+	for _, cache := range s.items {
+		dependencies = append(dependencies, store.CacheDependencies{
+			ID:           cache.ID,
+			Dimension:    cache.Dimension,
+			Workspace:    cache.Workspace,
+			Dependencies: cache.Dependencies,
+		})
+	}
+	return dependencies, nil
+}
+
 func (s *memoryCacheStore) Count() (int, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/content/store/storetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -48,3 +49,9 @@ func TestCache(t *testing.T) {
 	assert.NoError(t, errCountAfterRemove)
 	assert.Equal(t, 0, countAfterRemove)
 }
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.CacheStore {
+		return NewCacheStore()
+	})
+}
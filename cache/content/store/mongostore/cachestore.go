@@ -3,6 +3,7 @@ package mongostore
 import (
 	"github.com/foomo/neosproxy/cache/content"
 	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/logging"
 	"github.com/foomo/shop/persistence"
 	"github.com/pkg/errors"
 	"gopkg.in/mgo.v2/bson"
@@ -28,6 +29,20 @@ type mongoCacheStore struct {
 // ~ CONSTRUCTOR
 //------------------------------------------------------------------
 
+func init() {
+	store.Register("mongo", func(options map[string]string) (store.CacheStore, error) {
+		url := store.Option(options, store.OptionURL, "")
+		if url == "" {
+			return nil, errors.New("mongo: option url required")
+		}
+		s, errStore := NewMongoStore(url)
+		if errStore != nil {
+			return nil, errStore
+		}
+		return s.Cache(), nil
+	})
+}
+
 // NewCacheStore creates a new mongo cache store
 func NewCacheStore(p *persistence.Persistor) store.CacheStore {
 	s := &mongoCacheStore{persistor: p}
@@ -43,6 +58,11 @@ func (s mongoCacheStore) Upsert(cache store.CacheItem) (e error) {
 	session, collection := s.persistor.GetCollection()
 	defer session.Close()
 
+	// validate etag
+	if cache.Etag == "" {
+		cache.Etag = cache.GetEtag()
+	}
+
 	_, e = collection.Upsert(bson.M{"hash": cache.Hash}, cache)
 	return
 }
@@ -76,6 +96,51 @@ func (s mongoCacheStore) GetAll() (caches []store.CacheItem, e error) {
 	return
 }
 
+func (s mongoCacheStore) GetEtag(hash string) (etag string, e error) {
+	session, collection := s.persistor.GetCollection()
+	defer session.Close()
+
+	cache := store.CacheItem{}
+	errMongo := collection.Find(bson.M{"hash": hash}).Select(bson.M{"etag": 1, "html": 1}).One(&cache)
+	if errMongo != nil {
+		if errMongo == mgo.ErrNotFound {
+			e = content.ErrorNotFound
+			return
+		}
+		e = errMongo
+		return
+	}
+
+	etag = cache.GetEtag()
+	return
+}
+
+func (s mongoCacheStore) GetAllEtags(workspace string) (etags map[string]string) {
+	session, collection := s.persistor.GetCollection()
+	defer session.Close()
+
+	etags = make(map[string]string)
+
+	cache := store.CacheItem{}
+	iter := collection.Find(bson.M{"workspace": workspace}).Select(bson.M{"hash": 1, "etag": 1}).Iter()
+	for iter.Next(&cache) {
+		etags[cache.Hash] = cache.Etag
+	}
+	if errIter := iter.Close(); errIter != nil {
+		logging.GetDefaultLogEntry().WithError(errIter).WithField(logging.FieldWorkspace, workspace).Error("failed reading etags")
+	}
+	return
+}
+
+func (s mongoCacheStore) GetAllCacheDependencies() (dependencies []store.CacheDependencies, e error) {
+	session, collection := s.persistor.GetCollection()
+	defer session.Close()
+
+	dependencies = []store.CacheDependencies{}
+	e = collection.Find(bson.M{}).Select(bson.M{"id": 1, "dimension": 1, "workspace": 1, "dependencies": 1}).All(&dependencies)
+	return
+}
+
 func (s mongoCacheStore) Count() (int, error) {
 	session, collection := s.persistor.GetCollection()
 	defer session.Close()
@@ -88,6 +153,9 @@ func (s mongoCacheStore) Remove(hash string) (e error) {
 	defer session.Close()
 
 	e = collection.Remove(bson.M{"hash": hash})
+	if e == mgo.ErrNotFound {
+		e = nil
+	}
 	return
 }
 
@@ -0,0 +1,25 @@
+package mongostore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/content/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConformance requires a running mongodb, e.g. MONGO_URL=mongodb://localhost/neosproxy-test
+func TestConformance(t *testing.T) {
+	url := os.Getenv("MONGO_URL")
+	if url == "" {
+		t.Skip("MONGO_URL not set")
+	}
+
+	storetest.Run(t, func(t *testing.T) store.CacheStore {
+		s, errStore := NewMongoStore(url)
+		assert.NoError(t, errStore)
+		assert.NoError(t, s.Cache().RemoveAll())
+		return s.Cache()
+	})
+}
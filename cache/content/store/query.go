@@ -0,0 +1,106 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+// Lister may be implemented by cache stores which are able to filter cache items natively
+type Lister interface {
+	List(query Query) (items []CacheItemInfo, e error)
+	CountQuery(query Query) (count int, e error)
+}
+
+// Query to filter cache items, zero values will be ignored
+type Query struct {
+	Workspace     string
+	Dimension     string
+	CreatedBefore time.Time // only items created before
+	MinSize       int       // only items with at least MinSize bytes of html
+	Dependency    string    // only items depending on given node id
+
+	After string // pagination cursor: only items with a hash greater than After
+	Limit int
+}
+
+// CacheItemInfo contains a cache item's metadata without its html
+type CacheItemInfo struct {
+	Hash string
+
+	ID        string
+	Dimension string
+	Workspace string
+
+	Etag         string
+	Size         int
+	Created      time.Time
+	ValidUntil   time.Time
+	Dependencies []string
+}
+
+// NewCacheItemInfo will extract metadata from a cache item
+func NewCacheItemInfo(item CacheItem) CacheItemInfo {
+	return CacheItemInfo{
+		Hash:         item.Hash,
+		ID:           item.ID,
+		Dimension:    item.Dimension,
+		Workspace:    item.Workspace,
+		Etag:         item.GetEtag(),
+		Size:         len(item.HTML),
+		Created:      item.Created,
+		ValidUntil:   item.ValidUntil,
+		Dependencies: item.Dependencies,
+	}
+}
+
+// Match returns true if a cache item satisfies all query filters, pagination will not be considered
+func (q Query) Match(item CacheItem) bool {
+	if q.Workspace != "" && item.Workspace != q.Workspace {
+		return false
+	}
+	if q.Dimension != "" && item.Dimension != q.Dimension {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !item.Created.Before(q.CreatedBefore) {
+		return false
+	}
+	if q.MinSize > 0 && len(item.HTML) < q.MinSize {
+		return false
+	}
+	if q.Dependency != "" {
+		for _, dependency := range item.Dependencies {
+			if dependency == q.Dependency {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// List returns metadata of all cache items matching a query sorted by hash
+// stores not implementing Lister will be scanned completely
+func List(s CacheStore, query Query) (items []CacheItemInfo, e error) {
+	if lister, ok := s.(Lister); ok {
+		return lister.List(query)
+	}
+
+	items = []CacheItemInfo{}
+	e = ForEach(s, func(item CacheItem) error {
+		if item.Hash > query.After && query.Match(item) {
+			items = append(items, NewCacheItemInfo(item))
+		}
+		return nil
+	})
+	if e != nil {
+		return
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Hash < items[j].Hash
+	})
+	if query.Limit > 0 && len(items) > query.Limit {
+		items = items[:query.Limit]
+	}
+	return
+}
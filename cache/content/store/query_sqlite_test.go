@@ -0,0 +1,18 @@
+//go:build cgo
+// +build cgo
+
+package store_test
+
+import (
+	"testing"
+
+	"github.com/foomo/neosproxy/cache/content/store/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestListSqlite checks the native lister agrees with the fallback scan
+func TestListSqlite(t *testing.T) {
+	s, errSqlite := sqlite.NewCacheStore(":memory:")
+	assert.NoError(t, errSqlite)
+	testList(t, s)
+}
@@ -0,0 +1,48 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/content/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestList covers the fallback scan of stores without a native lister
+func TestList(t *testing.T) {
+	testList(t, memory.NewCacheStore())
+}
+
+func testList(t *testing.T, s store.CacheStore) {
+	validUntil := time.Now().Add(time.Hour)
+	for _, item := range []store.CacheItem{
+		store.NewCacheItem("a", "de", "live", "<h1>a</h1>", []string{"b"}, validUntil),
+		store.NewCacheItem("b", "de", "live", "<h1>b</h1>", nil, validUntil),
+		store.NewCacheItem("c", "de", "live", "<h1>c</h1>", []string{"b"}, validUntil),
+		store.NewCacheItem("a", "fr", "live", "<h1>a</h1>", nil, validUntil),
+		store.NewCacheItem("a", "de", "live-preview", "<h1>a</h1>", nil, validUntil),
+	} {
+		assert.NoError(t, s.Upsert(item))
+	}
+
+	items, errList := store.List(s, store.Query{Workspace: "live", Dimension: "de", Limit: 2})
+	assert.NoError(t, errList)
+	assert.Len(t, items, 2)
+	assert.Equal(t, store.GetHash("a", "de", "live"), items[0].Hash)
+	assert.Equal(t, store.GetHash("b", "de", "live"), items[1].Hash)
+	assert.Equal(t, len("<h1>a</h1>"), items[0].Size)
+
+	items, errList = store.List(s, store.Query{Workspace: "live", Dimension: "de", Limit: 2, After: items[1].Hash})
+	assert.NoError(t, errList)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "c", items[0].ID)
+
+	items, errList = store.List(s, store.Query{Dependency: "b"})
+	assert.NoError(t, errList)
+	assert.Len(t, items, 2)
+
+	items, errList = store.List(s, store.Query{CreatedBefore: time.Now().Add(-time.Hour)})
+	assert.NoError(t, errList)
+	assert.Empty(t, items)
+}
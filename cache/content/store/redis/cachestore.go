@@ -0,0 +1,545 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/logging"
+
+	goredis "github.com/go-redis/redis/v7"
+)
+
+//------------------------------------------------------------------
+// ~ CONSTANTS / VARS
+//------------------------------------------------------------------
+
+// DefaultPrefix for all keys written by the cache store
+const DefaultPrefix = "neosproxy:"
+
+// minTTL for items with a validUntil in the past, so they can be read right after an upsert
+const minTTL = time.Second
+
+// maxVersionRetries of a version added concurrently by another instance
+const maxVersionRetries = 5
+
+var _ store.VersionStore = &redisCacheStore{}
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// redisCacheStore implements a cache store shared by several neosproxy instances
+//
+// keys:
+//
+//	<prefix>item:<hash>          string => serialized cache item, expires at validUntil
+//	<prefix>etags                hash   => cache item hash => etag
+//	<prefix>index                hash   => cache item hash => serialized location (id, dimension, workspace)
+//	<prefix>workspace:<name>     set    => cache item hashes of a workspace
+//	<prefix>dependencies:<hash>  set    => dependencies of a cache item
+//	<prefix>versions:<hash>      list   => serialized versions of a cache item, oldest first
+//	<prefix>pinned               hash   => cache item hash => pinned version
+type redisCacheStore struct {
+	client *goredis.Client
+	prefix string
+	l      logging.Entry
+}
+
+//------------------------------------------------------------------
+// ~ CONSTRUCTOR
+//------------------------------------------------------------------
+
+func init() {
+	store.Register("redis", func(options map[string]string) (store.CacheStore, error) {
+		url := store.Option(options, store.OptionURL, "")
+		if url == "" {
+			return nil, errors.New("redis: option url required")
+		}
+		return NewCacheStore(url, store.Option(options, store.OptionPrefix, DefaultPrefix))
+	})
+}
+
+// NewCacheStore creates a new redis cache store, url format: redis://:password@host:port/db
+func NewCacheStore(url string, prefix string) (store.CacheStore, error) {
+	options, errOptions := goredis.ParseURL(url)
+	if errOptions != nil {
+		return nil, errOptions
+	}
+
+	client := goredis.NewClient(options)
+	if errPing := client.Ping().Err(); errPing != nil {
+		client.Close()
+		return nil, errPing
+	}
+
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+
+	return &redisCacheStore{
+		client: client,
+		prefix: prefix,
+		l:      logging.GetDefaultLogEntry().WithField("cache", "rediscache"),
+	}, nil
+}
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+func (s *redisCacheStore) Upsert(item store.CacheItem) (e error) {
+
+	// validate etag
+	if item.Etag == "" {
+		item.Etag = item.GetEtag()
+	}
+
+	// serialize
+	bytes, errMarshall := json.Marshal(item)
+	if errMarshall != nil {
+		return errMarshall
+	}
+	location, errMarshallLocation := json.Marshal(store.CacheDependencies{
+		ID:        item.ID,
+		Dimension: item.Dimension,
+		Workspace: item.Workspace,
+	})
+	if errMarshallLocation != nil {
+		return errMarshallLocation
+	}
+
+	// an item may have moved to another workspace
+	oldLocation, errOldLocation := s.getLocation(item.Hash)
+	if errOldLocation != nil && errOldLocation != content.ErrorNotFound {
+		return errOldLocation
+	}
+
+	_, e = s.client.TxPipelined(func(pipe goredis.Pipeliner) error {
+		if oldLocation.Workspace != "" && oldLocation.Workspace != item.Workspace {
+			pipe.SRem(s.keyWorkspace(oldLocation.Workspace), item.Hash)
+		}
+		pipe.Set(s.keyItem(item.Hash), bytes, ttl(item.ValidUntil))
+		pipe.HSet(s.keyEtags(), item.Hash, item.Etag)
+		pipe.HSet(s.keyIndex(), item.Hash, location)
+		pipe.SAdd(s.keyWorkspace(item.Workspace), item.Hash)
+		pipe.Del(s.keyDependencies(item.Hash))
+		if len(item.Dependencies) > 0 {
+			pipe.SAdd(s.keyDependencies(item.Hash), toInterfaces(item.Dependencies)...)
+		}
+		return nil
+	})
+	return
+}
+
+func (s *redisCacheStore) Get(hash string) (item store.CacheItem, e error) {
+	data, errGet := s.client.Get(s.keyItem(hash)).Bytes()
+	if errGet == goredis.Nil {
+		e = content.ErrorNotFound
+		return
+	} else if errGet != nil {
+		e = errGet
+		return
+	}
+
+	e = json.Unmarshal(data, &item)
+	return
+}
+
+func (s *redisCacheStore) GetAll() (items []store.CacheItem, e error) {
+	hashes, errHashes := s.client.HKeys(s.keyIndex()).Result()
+	if errHashes != nil {
+		e = errHashes
+		return
+	}
+
+	items = []store.CacheItem{}
+	if len(hashes) == 0 {
+		return
+	}
+
+	keys := make([]string, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = s.keyItem(hash)
+	}
+
+	values, errValues := s.client.MGet(keys...).Result()
+	if errValues != nil {
+		e = errValues
+		return
+	}
+
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			s.prune(hashes[i])
+			continue
+		}
+		item := store.CacheItem{}
+		if e = json.Unmarshal([]byte(data), &item); e != nil {
+			return
+		}
+		items = append(items, item)
+	}
+	return
+}
+
+func (s *redisCacheStore) GetEtag(hash string) (etag string, e error) {
+	var cmdEtag *goredis.StringCmd
+	var cmdExists *goredis.IntCmd
+	_, errPipe := s.client.Pipelined(func(pipe goredis.Pipeliner) error {
+		cmdEtag = pipe.HGet(s.keyEtags(), hash)
+		cmdExists = pipe.Exists(s.keyItem(hash))
+		return nil
+	})
+	if errPipe != nil && errPipe != goredis.Nil {
+		e = errPipe
+		return
+	}
+
+	if cmdExists.Val() == 0 {
+		s.prune(hash)
+		e = content.ErrorNotFound
+		return
+	}
+	if cmdEtag.Err() == goredis.Nil {
+		e = content.ErrorNotFound
+		return
+	}
+
+	etag = cmdEtag.Val()
+	return
+}
+
+func (s *redisCacheStore) GetAllEtags(workspace string) (etags map[string]string) {
+	etags = make(map[string]string)
+	l := s.l.WithField(logging.FieldWorkspace, workspace)
+
+	hashes, errHashes := s.client.SMembers(s.keyWorkspace(workspace)).Result()
+	if errHashes != nil {
+		l.WithError(errHashes).Error("failed reading etags")
+		return
+	}
+	if len(hashes) == 0 {
+		return
+	}
+
+	values, errValues := s.client.HMGet(s.keyEtags(), hashes...).Result()
+	if errValues != nil {
+		l.WithError(errValues).Error("failed reading etags")
+		return
+	}
+
+	exists := make([]*goredis.IntCmd, len(hashes))
+	_, errPipe := s.client.Pipelined(func(pipe goredis.Pipeliner) error {
+		for i, hash := range hashes {
+			exists[i] = pipe.Exists(s.keyItem(hash))
+		}
+		return nil
+	})
+	if errPipe != nil {
+		l.WithError(errPipe).Error("failed reading etags")
+		return
+	}
+
+	for i, hash := range hashes {
+		etag, ok := values[i].(string)
+		if !ok || exists[i].Val() == 0 {
+			s.prune(hash)
+			continue
+		}
+		etags[hash] = etag
+	}
+	return
+}
+
+func (s *redisCacheStore) GetAllCacheDependencies() ([]store.CacheDependencies, error) {
+	start := time.Now()
+	l := s.l.WithField(logging.FieldFunction, "GetAllCacheDependencies")
+
+	index, errIndex := s.client.HGetAll(s.keyIndex()).Result()
+	if errIndex != nil {
+		return nil, errIndex
+	}
+
+	hashes := make([]string, 0, len(index))
+	for hash := range index {
+		hashes = append(hashes, hash)
+	}
+	exists, errExists := s.existing(hashes)
+	if errExists != nil {
+		return nil, errExists
+	}
+
+	members := make([]*goredis.StringSliceCmd, len(hashes))
+	_, errPipe := s.client.Pipelined(func(pipe goredis.Pipeliner) error {
+		for i, hash := range hashes {
+			members[i] = pipe.SMembers(s.keyDependencies(hash))
+		}
+		return nil
+	})
+	if errPipe != nil {
+		return nil, errPipe
+	}
+
+	dependencies := []store.CacheDependencies{}
+	for i, hash := range hashes {
+		if !exists[i] {
+			continue
+		}
+		dependency := store.CacheDependencies{}
+		if errUnmarshal := json.Unmarshal([]byte(index[hash]), &dependency); errUnmarshal != nil {
+			l.WithError(errUnmarshal).Warn("could not load cache dependencies")
+			continue
+		}
+		dependency.Dependencies = members[i].Val()
+		dependencies = append(dependencies, dependency)
+	}
+
+	l.WithField("len", len(dependencies)).WithDuration(start).Debug("all cache dependencies loaded")
+	return dependencies, nil
+}
+
+// Count returns the number of cached items, index entries of expired items are pruned
+func (s *redisCacheStore) Count() (count int, e error) {
+	hashes, errHashes := s.client.HKeys(s.keyIndex()).Result()
+	if errHashes != nil {
+		return 0, errHashes
+	}
+	exists, errExists := s.existing(hashes)
+	if errExists != nil {
+		return 0, errExists
+	}
+	for _, ok := range exists {
+		if ok {
+			count++
+		}
+	}
+	return
+}
+
+func (s *redisCacheStore) Remove(hash string) (e error) {
+	location, errLocation := s.getLocation(hash)
+	if errLocation != nil && errLocation != content.ErrorNotFound {
+		return errLocation
+	}
+
+	_, e = s.client.TxPipelined(func(pipe goredis.Pipeliner) error {
+		pipe.Del(s.keyItem(hash), s.keyDependencies(hash))
+		pipe.HDel(s.keyEtags(), hash)
+		pipe.HDel(s.keyIndex(), hash)
+		if location.Workspace != "" {
+			pipe.SRem(s.keyWorkspace(location.Workspace), hash)
+		}
+		return nil
+	})
+	return
+}
+
+// RemoveAll removes all cache items, the version history is shared by all instances and kept
+func (s *redisCacheStore) RemoveAll() (e error) {
+	for _, pattern := range []string{s.keyItem("*"), s.keyWorkspace("*"), s.keyDependencies("*")} {
+		if e = s.deleteMatching(pattern); e != nil {
+			return
+		}
+	}
+	return s.client.Del(s.keyEtags(), s.keyIndex()).Err()
+}
+
+// AddVersion appends a version, its number is assigned atomically across all instances
+func (s *redisCacheStore) AddVersion(hash string, version store.Version, size int) (e error) {
+	key := s.keyVersions(hash)
+	add := func(tx *goredis.Tx) error {
+		version.Version = 1
+		latest, errLatest := tx.LIndex(key, -1).Bytes()
+		if errLatest != nil && errLatest != goredis.Nil {
+			return errLatest
+		}
+		if errLatest == nil {
+			previous := store.Version{}
+			if errUnmarshal := json.Unmarshal(latest, &previous); errUnmarshal != nil {
+				return errUnmarshal
+			}
+			if previous.Etag == version.Etag {
+				return tx.HDel(s.keyPinned(), hash).Err()
+			}
+			version.Version = previous.Version + 1
+		}
+
+		data, errMarshal := json.Marshal(version)
+		if errMarshal != nil {
+			return errMarshal
+		}
+		_, errPipe := tx.TxPipelined(func(pipe goredis.Pipeliner) error {
+			pipe.RPush(key, data)
+			pipe.LTrim(key, int64(-size), -1)
+			pipe.HDel(s.keyPinned(), hash)
+			return nil
+		})
+		return errPipe
+	}
+
+	for i := 0; i < maxVersionRetries; i++ {
+		if e = s.client.Watch(add, key); e != goredis.TxFailedErr {
+			return
+		}
+	}
+	return
+}
+
+func (s *redisCacheStore) GetVersions(hash string) (versions []store.Version, pinned int, e error) {
+	var cmdVersions *goredis.StringSliceCmd
+	var cmdPinned *goredis.StringCmd
+	_, errPipe := s.client.Pipelined(func(pipe goredis.Pipeliner) error {
+		cmdVersions = pipe.LRange(s.keyVersions(hash), 0, -1)
+		cmdPinned = pipe.HGet(s.keyPinned(), hash)
+		return nil
+	})
+	if errPipe != nil && errPipe != goredis.Nil {
+		e = errPipe
+		return
+	}
+
+	versions = make([]store.Version, len(cmdVersions.Val()))
+	for i, data := range cmdVersions.Val() {
+		if e = json.Unmarshal([]byte(data), &versions[i]); e != nil {
+			return
+		}
+	}
+	if cmdPinned.Err() == nil {
+		pinned, _ = cmdPinned.Int()
+	}
+	return
+}
+
+func (s *redisCacheStore) PinVersion(hash string, version int) (e error) {
+	return s.client.HSet(s.keyPinned(), hash, version).Err()
+}
+
+func (s *redisCacheStore) RemoveAllVersions() (e error) {
+	if e = s.deleteMatching(s.keyVersions("*")); e != nil {
+		return
+	}
+	return s.client.Del(s.keyPinned()).Err()
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+// getLocation of an item from the index
+func (s *redisCacheStore) getLocation(hash string) (location store.CacheDependencies, e error) {
+	data, errGet := s.client.HGet(s.keyIndex(), hash).Bytes()
+	if errGet == goredis.Nil {
+		e = content.ErrorNotFound
+		return
+	} else if errGet != nil {
+		e = errGet
+		return
+	}
+	e = json.Unmarshal(data, &location)
+	return
+}
+
+// existing reports which hashes have a cache item, index entries of expired items are pruned
+func (s *redisCacheStore) existing(hashes []string) ([]bool, error) {
+	result := make([]bool, len(hashes))
+	if len(hashes) == 0 {
+		return result, nil
+	}
+
+	exists := make([]*goredis.IntCmd, len(hashes))
+	_, errPipe := s.client.Pipelined(func(pipe goredis.Pipeliner) error {
+		for i, hash := range hashes {
+			exists[i] = pipe.Exists(s.keyItem(hash))
+		}
+		return nil
+	})
+	if errPipe != nil {
+		return nil, errPipe
+	}
+
+	for i, hash := range hashes {
+		result[i] = exists[i].Val() > 0
+		if !result[i] {
+			s.prune(hash)
+		}
+	}
+	return result, nil
+}
+
+// deleteMatching deletes all keys matching a pattern
+func (s *redisCacheStore) deleteMatching(pattern string) error {
+	var cursor uint64
+	for {
+		keys, next, errScan := s.client.Scan(cursor, pattern, 500).Result()
+		if errScan != nil {
+			return errScan
+		}
+		if len(keys) > 0 {
+			if errDel := s.client.Del(keys...).Err(); errDel != nil {
+				return errDel
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// prune index entries of an expired item
+func (s *redisCacheStore) prune(hash string) {
+	if errRemove := s.Remove(hash); errRemove != nil {
+		s.l.WithError(errRemove).WithField("hash", hash).Warn("failed pruning expired cache item")
+	}
+}
+
+func (s *redisCacheStore) keyItem(hash string) string {
+	return s.prefix + "item:" + hash
+}
+
+func (s *redisCacheStore) keyEtags() string {
+	return s.prefix + "etags"
+}
+
+func (s *redisCacheStore) keyIndex() string {
+	return s.prefix + "index"
+}
+
+func (s *redisCacheStore) keyWorkspace(workspace string) string {
+	return s.prefix + "workspace:" + workspace
+}
+
+func (s *redisCacheStore) keyDependencies(hash string) string {
+	return s.prefix + "dependencies:" + hash
+}
+
+func (s *redisCacheStore) keyVersions(hash string) string {
+	return s.prefix + "versions:" + hash
+}
+
+func (s *redisCacheStore) keyPinned() string {
+	return s.prefix + "pinned"
+}
+
+// ttl derived from validUntil, items valid forever will not expire
+func ttl(validUntil time.Time) time.Duration {
+	if validUntil.IsZero() || validUntil.Equal(store.ValidUntilForever) {
+		return 0
+	}
+	if d := time.Until(validUntil); d > minTTL {
+		return d
+	}
+	return minTTL
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
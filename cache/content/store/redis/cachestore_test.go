@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/content/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	mr, errRedis := miniredis.Run()
+	assert.NoError(t, errRedis)
+	defer mr.Close()
+
+	s, errStore := NewCacheStore("redis://"+mr.Addr(), "")
+	assert.NoError(t, errStore)
+
+	dependencies := []string{"foo", "bar"}
+
+	item := store.NewCacheItem("1234", "de", "live", "<h1>Test</h1>", dependencies, store.ValidUntilForever)
+	assert.NoError(t, s.Upsert(item))
+	assert.NoError(t, s.Upsert(store.NewCacheItem("1234", "de", "live-preview", "<h1>Preview</h1>", nil, time.Now().Add(time.Minute))))
+
+	count, countErr := s.Count()
+	assert.NoError(t, countErr)
+	assert.Equal(t, 2, count)
+
+	itemCached, errGet := s.Get(item.Hash)
+	assert.NoError(t, errGet)
+	assert.Equal(t, item.HTML, itemCached.HTML)
+	assert.Equal(t, dependencies, itemCached.Dependencies)
+
+	etags := s.GetAllEtags("live")
+	assert.Len(t, etags, 1)
+	assert.Equal(t, item.Etag, etags[item.Hash])
+
+	cacheDependencies, errDependencies := s.GetAllCacheDependencies()
+	assert.NoError(t, errDependencies)
+	assert.Len(t, cacheDependencies, 2)
+
+	// items expire at validUntil, they are neither counted nor listed before anybody read them
+	mr.FastForward(2 * time.Minute)
+	count, countErr = s.Count()
+	assert.NoError(t, countErr)
+	assert.Equal(t, 1, count)
+	cacheDependencies, errDependencies = s.GetAllCacheDependencies()
+	assert.NoError(t, errDependencies)
+	assert.Len(t, cacheDependencies, 1)
+	assert.Equal(t, "live", cacheDependencies[0].Workspace)
+	_, errGet = s.Get(store.GetHash("1234", "de", "live-preview"))
+	assert.Equal(t, content.ErrorNotFound, errGet)
+	assert.Empty(t, s.GetAllEtags("live-preview"))
+	count, countErr = s.Count()
+	assert.NoError(t, countErr)
+	assert.Equal(t, 1, count)
+
+	assert.NoError(t, s.Remove(item.Hash))
+	_, errEtag := s.GetEtag(item.Hash)
+	assert.Equal(t, content.ErrorNotFound, errEtag)
+
+	assert.NoError(t, s.Upsert(item))
+	assert.NoError(t, s.RemoveAll())
+	assert.Empty(t, mr.Keys())
+}
+
+func TestConformance(t *testing.T) {
+	mr, errRedis := miniredis.Run()
+	assert.NoError(t, errRedis)
+	defer mr.Close()
+
+	storetest.Run(t, func(t *testing.T) store.CacheStore {
+		mr.FlushAll()
+		s, errStore := NewCacheStore("redis://"+mr.Addr(), "")
+		assert.NoError(t, errStore)
+		return s
+	})
+}
+
+func TestVersions(t *testing.T) {
+	mr, errRedis := miniredis.Run()
+	assert.NoError(t, errRedis)
+	defer mr.Close()
+
+	s, errStore := NewCacheStore("redis://"+mr.Addr(), "")
+	assert.NoError(t, errStore)
+	versions := s.(store.VersionStore)
+
+	// the history is shared by all instances
+	other, errOther := NewCacheStore("redis://"+mr.Addr(), "")
+	assert.NoError(t, errOther)
+
+	assert.NoError(t, versions.AddVersion("1234", store.Version{Etag: "a", HTML: "<h1>A</h1>"}, 2))
+	assert.NoError(t, other.(store.VersionStore).AddVersion("1234", store.Version{Etag: "b", HTML: "<h1>B</h1>"}, 2))
+	assert.NoError(t, versions.PinVersion("1234", 1))
+
+	history, pinned, errGet := other.(store.VersionStore).GetVersions("1234")
+	assert.NoError(t, errGet)
+	assert.Len(t, history, 2)
+	assert.Equal(t, 1, history[0].Version)
+	assert.Equal(t, 2, history[1].Version)
+	assert.Equal(t, "<h1>B</h1>", history[1].HTML)
+	assert.Equal(t, 1, pinned)
+
+	// an unchanged document releases the pin without a new version
+	assert.NoError(t, versions.AddVersion("1234", store.Version{Etag: "b"}, 2))
+	history, pinned, _ = versions.GetVersions("1234")
+	assert.Len(t, history, 2)
+	assert.Equal(t, 0, pinned)
+
+	// only the last size versions are kept
+	assert.NoError(t, versions.AddVersion("1234", store.Version{Etag: "c"}, 2))
+	history, _, _ = versions.GetVersions("1234")
+	assert.Len(t, history, 2)
+	assert.Equal(t, 2, history[0].Version)
+	assert.Equal(t, 3, history[1].Version)
+
+	history, pinned, errGet = versions.GetVersions("unknown")
+	assert.NoError(t, errGet)
+	assert.Empty(t, history)
+	assert.Equal(t, 0, pinned)
+
+	assert.NoError(t, versions.PinVersion("1234", 2))
+
+	// clearing the cache keeps the history of all instances
+	assert.NoError(t, s.Upsert(store.NewCacheItem("1234", "de", "live", "<h1>C</h1>", nil, store.ValidUntilForever)))
+	assert.NoError(t, s.RemoveAll())
+	history, pinned, _ = versions.GetVersions("1234")
+	assert.Len(t, history, 2)
+	assert.Equal(t, 2, pinned)
+
+	assert.NoError(t, versions.RemoveAllVersions())
+	assert.Empty(t, mr.Keys())
+}
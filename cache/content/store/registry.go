@@ -0,0 +1,86 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// Factory creates a cache store from backend specific options
+type Factory func(options map[string]string) (CacheStore, error)
+
+//------------------------------------------------------------------
+// ~ CONSTANTS / VARS
+//------------------------------------------------------------------
+
+// well known backend options
+const (
+	OptionDirectory = "directory" // cache directory, defaults to <cache.directory>/content
+	OptionPath      = "path"      // database file
+	OptionURL       = "url"       // database server url
+	OptionPrefix    = "prefix"    // key prefix
+	OptionQuota     = "quota"     // size limit, e.g. 512M or 2G
+)
+
+var (
+	factoriesLock sync.RWMutex
+	factories     = map[string]Factory{}
+)
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+// Register a cache store backend, backends register themselves on import
+func Register(name string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	if factory == nil {
+		panic("store: register factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("store: register called twice for backend " + name)
+	}
+	factories[name] = factory
+}
+
+// Open a cache store of a registered backend
+func Open(name string, options map[string]string) (CacheStore, error) {
+	factoriesLock.RLock()
+	factory, ok := factories[name]
+	factoriesLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("store: unknown backend %q (forgotten import?), available: %v", name, Backends())
+	}
+	if options == nil {
+		options = map[string]string{}
+	}
+	return factory(options)
+}
+
+// Backends returns the sorted names of all registered backends
+func Backends() []string {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Option reads an option value or returns a fallback
+func Option(options map[string]string, key string, fallback string) string {
+	if value, ok := options[key]; ok && value != "" {
+		return value
+	}
+	return fallback
+}
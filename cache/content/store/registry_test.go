@@ -0,0 +1,26 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	Register("test-registry", func(options map[string]string) (CacheStore, error) {
+		assert.Equal(t, "bar", Option(options, "foo", "baz"))
+		assert.Equal(t, "baz", Option(options, "missing", "baz"))
+		return nil, nil
+	})
+	assert.Contains(t, Backends(), "test-registry")
+
+	_, errOpen := Open("test-registry", map[string]string{"foo": "bar"})
+	assert.NoError(t, errOpen)
+
+	_, errUnknown := Open("unknown", nil)
+	assert.Error(t, errUnknown)
+
+	assert.Panics(t, func() {
+		Register("test-registry", func(options map[string]string) (CacheStore, error) { return nil, nil })
+	})
+}
@@ -0,0 +1,463 @@
+//go:build cgo
+// +build cgo
+
+package sqlite
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/logging"
+
+	// sqlite driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//------------------------------------------------------------------
+// ~ CONSTANTS / VARS
+//------------------------------------------------------------------
+
+var _ store.Lister = &sqliteCacheStore{}
+var _ store.Iterator = &sqliteCacheStore{}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS cache_items (
+	hash        TEXT PRIMARY KEY,
+	id          TEXT NOT NULL,
+	dimension   TEXT NOT NULL,
+	workspace   TEXT NOT NULL,
+	html        TEXT NOT NULL,
+	etag        TEXT NOT NULL,
+	size        INTEGER NOT NULL,
+	created     DATETIME NOT NULL,
+	valid_until DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS cache_items_workspace_dimension ON cache_items (workspace, dimension);
+CREATE INDEX IF NOT EXISTS cache_items_created ON cache_items (created);
+
+CREATE TABLE IF NOT EXISTS cache_dependencies (
+	hash       TEXT NOT NULL,
+	position   INTEGER NOT NULL,
+	dependency TEXT NOT NULL,
+	PRIMARY KEY (hash, position)
+);
+CREATE INDEX IF NOT EXISTS cache_dependencies_dependency ON cache_dependencies (dependency);
+`
+
+const selectItemInfo = `SELECT hash, id, dimension, workspace, etag, size, created, valid_until FROM cache_items`
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// sqliteCacheStore implements a cache store on top of a sqlite database
+type sqliteCacheStore struct {
+	db *sql.DB
+	l  logging.Entry
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+//------------------------------------------------------------------
+// ~ CONSTRUCTOR
+//------------------------------------------------------------------
+
+func init() {
+	store.Register("sqlite", func(options map[string]string) (store.CacheStore, error) {
+		directory := store.Option(options, store.OptionDirectory, "")
+		filename := store.Option(options, store.OptionPath, filepath.Join(directory, "cache.sqlite"))
+		if filename != ":memory:" {
+			if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+				return nil, err
+			}
+		}
+		return NewCacheStore(filename)
+	})
+}
+
+// NewCacheStore creates a new sqlite cache store, use ":memory:" for an in-memory database
+func NewCacheStore(filename string) (store.CacheStore, error) {
+
+	l := logging.GetDefaultLogEntry().WithField("cache", "sqlitecache")
+
+	dsn := filename
+	if filename != ":memory:" {
+		dsn = "file:" + filename + "?_busy_timeout=5000&_journal_mode=WAL"
+	}
+
+	db, errOpen := sql.Open("sqlite3", dsn)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+
+	// sqlite allows a single writer only, serialize access instead of failing with "database is locked"
+	db.SetMaxOpenConns(1)
+
+	if _, errSchema := db.Exec(schema); errSchema != nil {
+		db.Close()
+		return nil, errSchema
+	}
+
+	return &sqliteCacheStore{
+		db: db,
+		l:  l,
+	}, nil
+}
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+func (s *sqliteCacheStore) Upsert(item store.CacheItem) (e error) {
+
+	// validate etag
+	if item.Etag == "" {
+		item.Etag = item.GetEtag()
+	}
+
+	tx, errTx := s.db.Begin()
+	if errTx != nil {
+		return errTx
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, e = tx.Exec(
+		`INSERT OR REPLACE INTO cache_items (hash, id, dimension, workspace, html, etag, size, created, valid_until) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		item.Hash, item.ID, item.Dimension, item.Workspace, item.HTML, item.Etag, len(item.HTML), item.Created.UTC(), item.ValidUntil.UTC(),
+	)
+	if e != nil {
+		return
+	}
+
+	if _, e = tx.Exec(`DELETE FROM cache_dependencies WHERE hash = ?`, item.Hash); e != nil {
+		return
+	}
+	for position, dependency := range item.Dependencies {
+		if _, e = tx.Exec(`INSERT INTO cache_dependencies (hash, position, dependency) VALUES (?, ?, ?)`, item.Hash, position, dependency); e != nil {
+			return
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteCacheStore) Get(hash string) (item store.CacheItem, e error) {
+	row := s.db.QueryRow(`SELECT hash, id, dimension, workspace, html, etag, created, valid_until FROM cache_items WHERE hash = ?`, hash)
+	errScan := row.Scan(&item.Hash, &item.ID, &item.Dimension, &item.Workspace, &item.HTML, &item.Etag, &item.Created, &item.ValidUntil)
+	if errScan == sql.ErrNoRows {
+		e = content.ErrorNotFound
+		return
+	} else if errScan != nil {
+		e = errScan
+		return
+	}
+
+	dependencies, errDependencies := s.getAllDependencies(`WHERE hash = ?`, hash)
+	if errDependencies != nil {
+		e = errDependencies
+		return
+	}
+	item.Dependencies = dependencies[hash]
+	return
+}
+
+func (s *sqliteCacheStore) GetAll() (items []store.CacheItem, e error) {
+	rows, errQuery := s.db.Query(`SELECT hash, id, dimension, workspace, html, etag, created, valid_until FROM cache_items ORDER BY hash`)
+	if errQuery != nil {
+		e = errQuery
+		return
+	}
+	defer rows.Close()
+
+	items = []store.CacheItem{}
+	for rows.Next() {
+		item := store.CacheItem{}
+		if e = rows.Scan(&item.Hash, &item.ID, &item.Dimension, &item.Workspace, &item.HTML, &item.Etag, &item.Created, &item.ValidUntil); e != nil {
+			return
+		}
+		items = append(items, item)
+	}
+	if e = rows.Err(); e != nil {
+		return
+	}
+
+	dependencies, errDependencies := s.getAllDependencies("")
+	if errDependencies != nil {
+		e = errDependencies
+		return
+	}
+	for i := range items {
+		items[i].Dependencies = dependencies[items[i].Hash]
+	}
+	return
+}
+
+// ForEach streams all cached items ordered by hash
+func (s *sqliteCacheStore) ForEach(fn func(item store.CacheItem) error) error {
+	rows, errQuery := s.db.Query(`SELECT hash FROM cache_items ORDER BY hash`)
+	if errQuery != nil {
+		return errQuery
+	}
+	defer rows.Close()
+
+	// collect hashes first, the connection is exclusive while rows are open
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if errScan := rows.Scan(&hash); errScan != nil {
+			return errScan
+		}
+		hashes = append(hashes, hash)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return errRows
+	}
+
+	for _, hash := range hashes {
+		item, errGet := s.Get(hash)
+		if errGet == content.ErrorNotFound {
+			continue
+		} else if errGet != nil {
+			return errGet
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteCacheStore) GetEtag(hash string) (etag string, e error) {
+	errScan := s.db.QueryRow(`SELECT etag FROM cache_items WHERE hash = ?`, hash).Scan(&etag)
+	if errScan == sql.ErrNoRows {
+		e = content.ErrorNotFound
+		return
+	}
+	e = errScan
+	return
+}
+
+func (s *sqliteCacheStore) GetAllEtags(workspace string) (etags map[string]string) {
+	etags = make(map[string]string)
+
+	rows, errQuery := s.db.Query(`SELECT hash, etag FROM cache_items WHERE workspace = ?`, workspace)
+	if errQuery != nil {
+		s.l.WithError(errQuery).WithField(logging.FieldWorkspace, workspace).Error("failed reading etags")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash, etag string
+		if errScan := rows.Scan(&hash, &etag); errScan != nil {
+			s.l.WithError(errScan).WithField(logging.FieldWorkspace, workspace).Error("failed reading etags")
+			return
+		}
+		etags[hash] = etag
+	}
+	return
+}
+
+func (s *sqliteCacheStore) GetAllCacheDependencies() ([]store.CacheDependencies, error) {
+	start := time.Now()
+	l := s.l.WithField(logging.FieldFunction, "GetAllCacheDependencies")
+
+	rows, errQuery := s.db.Query(`SELECT hash, id, dimension, workspace FROM cache_items ORDER BY hash`)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+	defer rows.Close()
+
+	hashes := []string{}
+	cacheDependencies := []store.CacheDependencies{}
+	for rows.Next() {
+		var hash string
+		obj := store.CacheDependencies{}
+		if errScan := rows.Scan(&hash, &obj.ID, &obj.Dimension, &obj.Workspace); errScan != nil {
+			return nil, errScan
+		}
+		hashes = append(hashes, hash)
+		cacheDependencies = append(cacheDependencies, obj)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	dependencies, errDependencies := s.getAllDependencies("")
+	if errDependencies != nil {
+		return nil, errDependencies
+	}
+	for i, hash := range hashes {
+		cacheDependencies[i].Dependencies = dependencies[hash]
+	}
+
+	l.WithField("len", len(cacheDependencies)).WithDuration(start).Debug("all cache dependencies loaded")
+	return cacheDependencies, nil
+}
+
+func (s *sqliteCacheStore) Count() (count int, e error) {
+	e = s.db.QueryRow(`SELECT COUNT(*) FROM cache_items`).Scan(&count)
+	return
+}
+
+// List cache item metadata matching a query ordered by hash
+func (s *sqliteCacheStore) List(query store.Query) (items []store.CacheItemInfo, e error) {
+	where, args := buildWhere(query, true)
+	statement := selectItemInfo + where + ` ORDER BY hash`
+	if query.Limit > 0 {
+		statement += ` LIMIT ?`
+		args = append(args, query.Limit)
+	}
+
+	rows, errQuery := s.db.Query(statement, args...)
+	if errQuery != nil {
+		e = errQuery
+		return
+	}
+	defer rows.Close()
+
+	items = []store.CacheItemInfo{}
+	for rows.Next() {
+		info, errScan := scanItemInfo(rows)
+		if errScan != nil {
+			e = errScan
+			return
+		}
+		items = append(items, info)
+	}
+	if e = rows.Err(); e != nil {
+		return
+	}
+
+	if len(items) == 0 {
+		return
+	}
+
+	// attach dependencies of listed items only
+	hashes := make([]interface{}, len(items))
+	for i, item := range items {
+		hashes[i] = item.Hash
+	}
+	dependencies, errDependencies := s.getAllDependencies(`WHERE hash IN (?`+strings.Repeat(`, ?`, len(hashes)-1)+`)`, hashes...)
+	if errDependencies != nil {
+		e = errDependencies
+		return
+	}
+	for i := range items {
+		items[i].Dependencies = dependencies[items[i].Hash]
+	}
+	return
+}
+
+// CountQuery counts items matching a query, pagination will be ignored
+func (s *sqliteCacheStore) CountQuery(query store.Query) (count int, e error) {
+	where, args := buildWhere(query, false)
+	e = s.db.QueryRow(`SELECT COUNT(*) FROM cache_items`+where, args...).Scan(&count)
+	return
+}
+
+func (s *sqliteCacheStore) Remove(hash string) (e error) {
+	tx, errTx := s.db.Begin()
+	if errTx != nil {
+		return errTx
+	}
+	if _, e = tx.Exec(`DELETE FROM cache_dependencies WHERE hash = ?`, hash); e != nil {
+		tx.Rollback()
+		return
+	}
+	if _, e = tx.Exec(`DELETE FROM cache_items WHERE hash = ?`, hash); e != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteCacheStore) RemoveAll() (e error) {
+	tx, errTx := s.db.Begin()
+	if errTx != nil {
+		return errTx
+	}
+	if _, e = tx.Exec(`DELETE FROM cache_dependencies`); e != nil {
+		tx.Rollback()
+		return
+	}
+	if _, e = tx.Exec(`DELETE FROM cache_items`); e != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+// getAllDependencies returns ordered dependencies grouped by hash
+func (s *sqliteCacheStore) getAllDependencies(where string, args ...interface{}) (map[string][]string, error) {
+	rows, errQuery := s.db.Query(`SELECT hash, dependency FROM cache_dependencies `+where+` ORDER BY hash, position`, args...)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+	defer rows.Close()
+
+	dependencies := map[string][]string{}
+	for rows.Next() {
+		var hash, dependency string
+		if errScan := rows.Scan(&hash, &dependency); errScan != nil {
+			return nil, errScan
+		}
+		dependencies[hash] = append(dependencies[hash], dependency)
+	}
+	return dependencies, rows.Err()
+}
+
+func buildWhere(query store.Query, paginate bool) (string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+
+	if query.Workspace != "" {
+		conditions = append(conditions, `workspace = ?`)
+		args = append(args, query.Workspace)
+	}
+	if query.Dimension != "" {
+		conditions = append(conditions, `dimension = ?`)
+		args = append(args, query.Dimension)
+	}
+	if !query.CreatedBefore.IsZero() {
+		conditions = append(conditions, `created < ?`)
+		args = append(args, query.CreatedBefore.UTC())
+	}
+	if query.MinSize > 0 {
+		conditions = append(conditions, `size >= ?`)
+		args = append(args, query.MinSize)
+	}
+	if query.Dependency != "" {
+		conditions = append(conditions, `hash IN (SELECT hash FROM cache_dependencies WHERE dependency = ?)`)
+		args = append(args, query.Dependency)
+	}
+	if paginate && query.After != "" {
+		conditions = append(conditions, `hash > ?`)
+		args = append(args, query.After)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return ` WHERE ` + strings.Join(conditions, ` AND `), args
+}
+
+func scanItemInfo(row scanner) (info store.CacheItemInfo, e error) {
+	e = row.Scan(&info.Hash, &info.ID, &info.Dimension, &info.Workspace, &info.Etag, &info.Size, &info.Created, &info.ValidUntil)
+	return
+}
@@ -0,0 +1,86 @@
+//go:build cgo
+// +build cgo
+
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/content/store/storetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	s, errStore := NewCacheStore(":memory:")
+	assert.NoError(t, errStore)
+
+	dependencies := []string{"foo", "bar"}
+	validUntil := time.Now().Add(time.Hour)
+
+	item := store.NewCacheItem("1234", "de", "live", "<h1>Test</h1>", dependencies, validUntil)
+	assert.NoError(t, s.Upsert(item))
+	assert.NoError(t, s.Upsert(store.NewCacheItem("5678", "fr", "live", "<h1>Test FR</h1>", []string{"bar"}, validUntil)))
+	assert.NoError(t, s.Upsert(store.NewCacheItem("1234", "de", "live-preview", "<h1>Preview</h1>", nil, validUntil)))
+
+	count, countErr := s.Count()
+	assert.NoError(t, countErr)
+	assert.Equal(t, 3, count)
+
+	itemCached, errGet := s.Get(item.Hash)
+	assert.NoError(t, errGet)
+	assert.Equal(t, item.HTML, itemCached.HTML)
+	assert.Equal(t, item.Etag, itemCached.Etag)
+	assert.Equal(t, dependencies, itemCached.Dependencies)
+	assert.True(t, item.Created.Equal(itemCached.Created))
+	assert.True(t, validUntil.Equal(itemCached.ValidUntil))
+
+	etags := s.GetAllEtags("live")
+	assert.Len(t, etags, 2)
+
+	lister := s.(store.Lister)
+
+	infos, errList := lister.List(store.Query{Dependency: "bar"})
+	assert.NoError(t, errList)
+	assert.Len(t, infos, 2)
+
+	infos, errList = lister.List(store.Query{Workspace: "live", Dimension: "de"})
+	assert.NoError(t, errList)
+	assert.Len(t, infos, 1)
+	assert.Equal(t, len(item.HTML), infos[0].Size)
+	assert.Equal(t, dependencies, infos[0].Dependencies)
+
+	page, errPage := lister.List(store.Query{Limit: 2})
+	assert.NoError(t, errPage)
+	assert.Len(t, page, 2)
+	page, errPage = lister.List(store.Query{Limit: 2, After: page[1].Hash})
+	assert.NoError(t, errPage)
+	assert.Len(t, page, 1)
+
+	counted, errCount := lister.CountQuery(store.Query{Workspace: "live", After: "zzz"})
+	assert.NoError(t, errCount)
+	assert.Equal(t, 2, counted)
+
+	counted, errCount = lister.CountQuery(store.Query{CreatedBefore: item.Created})
+	assert.NoError(t, errCount)
+	assert.Equal(t, 0, counted)
+
+	assert.NoError(t, s.Remove(item.Hash))
+	_, errGet = s.Get(item.Hash)
+	assert.Equal(t, content.ErrorNotFound, errGet)
+
+	assert.NoError(t, s.RemoveAll())
+	countAfterRemove, errCountAfterRemove := s.Count()
+	assert.NoError(t, errCountAfterRemove)
+	assert.Equal(t, 0, countAfterRemove)
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.CacheStore {
+		s, errStore := NewCacheStore(":memory:")
+		assert.NoError(t, errStore)
+		return s
+	})
+}
@@ -0,0 +1,320 @@
+// Package storetest provides a conformance test suite for store.CacheStore implementations
+//
+// usage in a backend test:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.CacheStore {
+//			return NewCacheStore()
+//		})
+//	}
+package storetest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// Factory must return a new and empty cache store for every call
+type Factory func(t *testing.T) store.CacheStore
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+// Run the conformance test suite against cache stores created by factory
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s store.CacheStore)
+	}{
+		{"UpsertGet", testUpsertGet},
+		{"UpsertReplace", testUpsertReplace},
+		{"NotFound", testNotFound},
+		{"Etag", testEtag},
+		{"Remove", testRemove},
+		{"RemoveAll", testRemoveAll},
+		{"GetAll", testGetAll},
+		{"GetAllEtags", testGetAllEtags},
+		{"GetAllCacheDependencies", testGetAllCacheDependencies},
+		{"Keys", testKeys},
+		{"Concurrency", testConcurrency},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, factory(t))
+		})
+	}
+}
+
+//------------------------------------------------------------------
+// ~ TESTS
+//------------------------------------------------------------------
+
+func testUpsertGet(t *testing.T, s store.CacheStore) {
+	item := newItem("1", "de", "live", "<h1>1</h1>", "2", "3")
+	require.NoError(t, s.Upsert(item))
+
+	cached, errGet := s.Get(item.Hash)
+	require.NoError(t, errGet)
+	assertItem(t, item, cached)
+
+	count, errCount := s.Count()
+	require.NoError(t, errCount)
+	assert.Equal(t, 1, count)
+}
+
+func testUpsertReplace(t *testing.T, s store.CacheStore) {
+	item := newItem("1", "de", "live", "<h1>old</h1>", "2")
+	require.NoError(t, s.Upsert(item))
+
+	replacement := newItem("1", "de", "live", "<h1>new</h1>", "3")
+	require.NoError(t, s.Upsert(replacement))
+
+	cached, errGet := s.Get(item.Hash)
+	require.NoError(t, errGet)
+	assertItem(t, replacement, cached)
+
+	etag, errEtag := s.GetEtag(item.Hash)
+	require.NoError(t, errEtag)
+	assert.Equal(t, replacement.Etag, etag)
+
+	count, errCount := s.Count()
+	require.NoError(t, errCount)
+	assert.Equal(t, 1, count)
+}
+
+func testNotFound(t *testing.T, s store.CacheStore) {
+	hash := store.GetHash("missing", "de", "live")
+
+	_, errGet := s.Get(hash)
+	assert.Equal(t, content.ErrorNotFound, errGet)
+
+	_, errEtag := s.GetEtag(hash)
+	assert.Equal(t, content.ErrorNotFound, errEtag)
+}
+
+func testEtag(t *testing.T, s store.CacheStore) {
+	// etag will be generated if missing
+	item := newItem("1", "de", "live", "<h1>1</h1>")
+	expected := item.Etag
+	item.Etag = ""
+	require.NoError(t, s.Upsert(item))
+
+	etag, errEtag := s.GetEtag(item.Hash)
+	require.NoError(t, errEtag)
+	assert.Equal(t, expected, etag)
+
+	cached, errGet := s.Get(item.Hash)
+	require.NoError(t, errGet)
+	assert.Equal(t, expected, cached.Etag)
+	assert.Equal(t, expected, cached.GetEtag())
+}
+
+func testRemove(t *testing.T, s store.CacheStore) {
+	item := newItem("1", "de", "live", "<h1>1</h1>", "2")
+	other := newItem("2", "de", "live", "<h1>2</h1>")
+	require.NoError(t, s.Upsert(item))
+	require.NoError(t, s.Upsert(other))
+
+	require.NoError(t, s.Remove(item.Hash))
+
+	_, errGet := s.Get(item.Hash)
+	assert.Equal(t, content.ErrorNotFound, errGet)
+	_, errEtag := s.GetEtag(item.Hash)
+	assert.Equal(t, content.ErrorNotFound, errEtag)
+	assert.Equal(t, map[string]string{other.Hash: other.Etag}, s.GetAllEtags("live"))
+
+	dependencies, errDependencies := s.GetAllCacheDependencies()
+	require.NoError(t, errDependencies)
+	assert.Len(t, dependencies, 1)
+
+	count, errCount := s.Count()
+	require.NoError(t, errCount)
+	assert.Equal(t, 1, count)
+
+	// removing a missing item is not an error
+	assert.NoError(t, s.Remove(item.Hash))
+	assert.NoError(t, s.Remove(store.GetHash("missing", "de", "live")))
+}
+
+func testRemoveAll(t *testing.T, s store.CacheStore) {
+	require.NoError(t, s.Upsert(newItem("1", "de", "live", "<h1>1</h1>")))
+	require.NoError(t, s.Upsert(newItem("1", "fr", "stage", "<h1>1</h1>")))
+
+	require.NoError(t, s.RemoveAll())
+
+	count, errCount := s.Count()
+	require.NoError(t, errCount)
+	assert.Equal(t, 0, count)
+
+	items, errGetAll := s.GetAll()
+	require.NoError(t, errGetAll)
+	assert.Empty(t, items)
+	assert.Empty(t, s.GetAllEtags("live"))
+
+	// store must be usable after removing all items
+	item := newItem("2", "de", "live", "<h1>2</h1>")
+	require.NoError(t, s.Upsert(item))
+	_, errGet := s.Get(item.Hash)
+	assert.NoError(t, errGet)
+}
+
+func testGetAll(t *testing.T, s store.CacheStore) {
+	items, errGetAll := s.GetAll()
+	require.NoError(t, errGetAll)
+	assert.Empty(t, items)
+
+	expected := map[string]store.CacheItem{}
+	for i := 0; i < 10; i++ {
+		item := newItem(fmt.Sprint(i), "de", "live", fmt.Sprintf("<h1>%d</h1>", i))
+		require.NoError(t, s.Upsert(item))
+		expected[item.Hash] = item
+	}
+
+	items, errGetAll = s.GetAll()
+	require.NoError(t, errGetAll)
+	require.Len(t, items, len(expected))
+	for _, item := range items {
+		assertItem(t, expected[item.Hash], item)
+	}
+}
+
+func testGetAllEtags(t *testing.T, s store.CacheStore) {
+	live := newItem("1", "de", "live", "<h1>live</h1>")
+	liveFr := newItem("1", "fr", "live", "<h1>live fr</h1>")
+	preview := newItem("1", "de", "live-preview", "<h1>preview</h1>")
+	stage := newItem("1", "de", "stage", "<h1>stage</h1>")
+	for _, item := range []store.CacheItem{live, liveFr, preview, stage} {
+		require.NoError(t, s.Upsert(item))
+	}
+
+	assert.Equal(t, map[string]string{live.Hash: live.Etag, liveFr.Hash: liveFr.Etag}, s.GetAllEtags("live"))
+	assert.Equal(t, map[string]string{preview.Hash: preview.Etag}, s.GetAllEtags("live-preview"))
+	assert.Equal(t, map[string]string{stage.Hash: stage.Etag}, s.GetAllEtags("stage"))
+	assert.Empty(t, s.GetAllEtags("unknown"))
+}
+
+func testGetAllCacheDependencies(t *testing.T, s store.CacheStore) {
+	require.NoError(t, s.Upsert(newItem("1", "de", "live", "<h1>1</h1>", "2", "3")))
+	require.NoError(t, s.Upsert(newItem("2", "fr", "stage", "<h1>2</h1>", "3")))
+	require.NoError(t, s.Upsert(newItem("3", "de", "live", "<h1>3</h1>")))
+
+	dependencies, errDependencies := s.GetAllCacheDependencies()
+	require.NoError(t, errDependencies)
+	require.Len(t, dependencies, 3)
+
+	byID := map[string]store.CacheDependencies{}
+	for _, dependency := range dependencies {
+		sort.Strings(dependency.Dependencies)
+		byID[dependency.ID] = dependency
+	}
+
+	assert.Equal(t, "de", byID["1"].Dimension)
+	assert.Equal(t, "live", byID["1"].Workspace)
+	assert.Equal(t, []string{"2", "3"}, byID["1"].Dependencies)
+	assert.Equal(t, "fr", byID["2"].Dimension)
+	assert.Equal(t, "stage", byID["2"].Workspace)
+	assert.Equal(t, []string{"3"}, byID["2"].Dependencies)
+	assert.Empty(t, byID["3"].Dependencies)
+}
+
+func testKeys(t *testing.T, s store.CacheStore) {
+	// values containing the key separator and characters not allowed in file names
+	item := newItem("a_b/c%d", "de_CH", "user_admin", "<h1>1</h1>")
+	other := newItem("c%d", "CH", "user_admin_de_a_b", "<h1>2</h1>")
+	require.NotEqual(t, item.Hash, other.Hash)
+	require.NoError(t, s.Upsert(item))
+	require.NoError(t, s.Upsert(other))
+
+	cached, errGet := s.Get(item.Hash)
+	require.NoError(t, errGet)
+	assertItem(t, item, cached)
+
+	key, errKey := store.ParseKey(cached.Hash)
+	require.NoError(t, errKey)
+	assert.Equal(t, cached.Key(), key)
+
+	assert.Equal(t, map[string]string{item.Hash: item.Etag}, s.GetAllEtags("user_admin"))
+	require.NoError(t, s.Remove(item.Hash))
+	_, errGet = s.Get(other.Hash)
+	assert.NoError(t, errGet)
+}
+
+func testConcurrency(t *testing.T, s store.CacheStore) {
+	workers := 8
+	iterations := 25
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				// shared and worker specific items
+				shared := newItem(fmt.Sprint(i%5), "de", "live", fmt.Sprintf("<h1>%d %d</h1>", w, i))
+				own := newItem(fmt.Sprintf("%d-%d", w, i), "de", "stage", "<h1>own</h1>")
+
+				assert.NoError(t, s.Upsert(shared))
+				assert.NoError(t, s.Upsert(own))
+
+				_, errGet := s.Get(shared.Hash)
+				assert.True(t, errGet == nil || errGet == content.ErrorNotFound, "unexpected error: %v", errGet)
+				_, errEtag := s.GetEtag(own.Hash)
+				assert.NoError(t, errEtag)
+
+				s.GetAllEtags("live")
+				_, errCount := s.Count()
+				assert.NoError(t, errCount)
+
+				if i%2 == 0 {
+					assert.NoError(t, s.Remove(own.Hash))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// all shared items and every second own item per worker
+	count, errCount := s.Count()
+	require.NoError(t, errCount)
+	assert.Equal(t, 5+workers*(iterations/2), count)
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+func newItem(id, dimension, workspace, html string, dependencies ...string) store.CacheItem {
+	if dependencies == nil {
+		dependencies = []string{}
+	}
+	item := store.NewCacheItem(id, dimension, workspace, html, dependencies, time.Now().Add(time.Hour).Truncate(time.Second))
+	// strip monotonic clock and sub-second precision, not all backends persist it
+	item.Created = item.Created.Truncate(time.Second)
+	return item
+}
+
+func assertItem(t *testing.T, expected store.CacheItem, actual store.CacheItem) {
+	assert.Equal(t, expected.Hash, actual.Hash)
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.Dimension, actual.Dimension)
+	assert.Equal(t, expected.Workspace, actual.Workspace)
+	assert.Equal(t, expected.HTML, actual.HTML)
+	assert.Equal(t, expected.Etag, actual.Etag)
+	assert.ElementsMatch(t, expected.Dependencies, actual.Dependencies)
+	assert.True(t, expected.Created.Equal(actual.Created), "created: expected %v, got %v", expected.Created, actual.Created)
+	assert.True(t, expected.ValidUntil.Equal(actual.ValidUntil), "validUntil: expected %v, got %v", expected.ValidUntil, actual.ValidUntil)
+}
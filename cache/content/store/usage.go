@@ -0,0 +1,24 @@
+package store
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// Usage of a size limited cache store
+type Usage struct {
+	Items     int
+	Bytes     uint64 // size of all cached items
+	Quota     uint64 // 0 === unlimited
+	Evictions uint64 // items evicted to stay within quota
+}
+
+// UsageReporter is implemented by cache stores tracking their size
+type UsageReporter interface {
+	Usage() Usage
+}
+
+// Peeker is implemented by cache stores tracking when items are served,
+// Peek reads an item without marking it as served
+type Peeker interface {
+	Peek(hash string) (item CacheItem, e error)
+}
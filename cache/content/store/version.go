@@ -0,0 +1,28 @@
+package store
+
+import "time"
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// Version of a cached document
+type Version struct {
+	Version      int       `json:"version"` // sequence number, increasing per document, assigned by AddVersion
+	Etag         string    `json:"etag"`
+	HTML         string    `json:"html"`
+	Dependencies []string  `json:"dependencies"`
+	Created      time.Time `json:"created"`
+}
+
+// VersionStore may be implemented by cache stores shared by several instances,
+// the version history of cached documents and their pins are kept next to the documents and are seen by all instances
+type VersionStore interface {
+	// AddVersion appends a version to the history of a document and releases its pin,
+	// a version with the etag of the latest version is skipped, only the last size versions are kept
+	AddVersion(hash string, version Version, size int) (e error)
+	// GetVersions returns the history of a document, oldest first, and the pinned version (0 === none)
+	GetVersions(hash string) (versions []Version, pinned int, e error)
+	PinVersion(hash string, version int) (e error)
+	RemoveAllVersions() (e error)
+}
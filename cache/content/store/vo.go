@@ -3,7 +3,6 @@ package store
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"strings"
 	"time"
 )
 
@@ -17,8 +16,8 @@ type CacheItem struct {
 	Dimension string
 	Workspace string
 
-	created    time.Time
-	validUntil time.Time
+	Created    time.Time
+	ValidUntil time.Time
 
 	HTML         string
 	Etag         string // hashed fingerprint of html content
@@ -39,8 +38,8 @@ func NewCacheItem(id string, dimension string, workspace string, html string, de
 		ID:           id,
 		Dimension:    dimension,
 		Workspace:    workspace,
-		created:      time.Now(),
-		validUntil:   validUntil,
+		Created:      time.Now(),
+		ValidUntil:   validUntil,
 		HTML:         html,
 		Etag:         generateFingerprint(html),
 		Dependencies: dependencies,
@@ -55,9 +54,14 @@ func (item *CacheItem) GetEtag() string {
 	return generateFingerprint(item.HTML)
 }
 
-// GetHash will return a cache item hash
+// Key returns the key of a cache item
+func (item *CacheItem) Key() Key {
+	return NewKey(item.ID, item.Dimension, item.Workspace)
+}
+
+// GetHash will return a cache item hash, see Key.String
 func GetHash(id, dimension, workspace string) string {
-	return strings.Join([]string{workspace, dimension, id}, "_")
+	return NewKey(id, dimension, workspace).String()
 }
 
 func generateFingerprint(data string) string {
@@ -2,11 +2,13 @@ package content
 
 import (
 	"container/list"
+	"sync"
 	"time"
 
 	"github.com/foomo/neosproxy/cache/content/store"
 	"github.com/foomo/neosproxy/client/cms"
 	"github.com/foomo/neosproxy/logging"
+	"github.com/foomo/neosproxy/model"
 	"golang.org/x/sync/singleflight"
 )
 
@@ -22,8 +24,12 @@ type Cache struct {
 	retryQueue               *list.List
 
 	cacheDependencies *cacheDependencies
+	history           *history
 	lifetime          time.Duration // time until an item must be re-invalidated (< 0 === never)
 
+	lockStatus sync.RWMutex
+	status     model.ContentCacheStatus
+
 	log logging.Entry
 }
 
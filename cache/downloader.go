@@ -1,46 +1,150 @@
 package cache
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"net/http"
+	"io"
+	"io/ioutil"
 	"os"
+
+	"github.com/foomo/neosproxy/client/cms"
 )
 
-// downloadNeosContentServerExport ...
-func downloadNeosContentServerExport(filename string, neosURL string) error {
+// ErrorEmptyExport in case the downloaded export does not contain any json
+var ErrorEmptyExport = errors.New("empty contentserver export")
+
+// errorTrailingData in case the export contains more than one json value
+var errorTrailingData = errors.New("invalid contentserver export: data after the top-level value")
+
+// downloadNeosContentServerExport streams the export of the workspace into a file and returns its md5 hash
+// the json is validated token by token while it is written and hashed, memory usage does not depend on the size of the export
+// cms.ErrorNotModified will be returned if NEOS reports the current export as unchanged, partial files are removed
+func (c *Cache) downloadNeosContentServerExport(filename string) (hash string, version cms.RepositoryVersion, err error) {
+
+	// conditional request, if NEOS sent the current export
+	since := c.getUpstreamVersion()
+
+	repository, errRepository := c.loader.GetRepository(c.Workspace, since, c.ctx)
+	if errRepository != nil {
+		err = errRepository
+		return
+	}
+	defer repository.Body.Close()
+
 	file, errFile := os.Create(filename)
 	if errFile != nil {
-		return errFile
+		err = errFile
+		return
 	}
-	defer file.Close()
+	defer func() {
+		if errClose := file.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			os.Remove(filename)
+		}
+	}()
 
-	response, errGetExport := http.Get(neosURL)
-	if errGetExport != nil {
-		return errGetExport
+	// one pass: read => validate, write and hash
+	hasher := md5.New()
+	if err = validateJSONStream(io.TeeReader(repository.Body, io.MultiWriter(file, hasher))); err != nil {
+		return
 	}
-	if response.StatusCode != http.StatusOK {
-		return errors.New(fmt.Sprintln("unexpected status code from site contentserver export", response.StatusCode, response.Status))
+
+	if err = file.Sync(); err != nil {
+		return
 	}
-	defer response.Body.Close()
+	hash = hex.EncodeToString(hasher.Sum(nil))
+	version = repository.Version
+	return
+}
 
-	// obj for tmp. json decoding
-	var obj interface{}
+// loadUpstreamVersion restores the validators NEOS sent for an export
+func (c *Cache) loadUpstreamVersion() error {
+	data, errRead := ioutil.ReadFile(c.upstreamFilename())
+	if errRead != nil {
+		if os.IsNotExist(errRead) {
+			return nil
+		}
+		return errRead
+	}
+	c.upstreamLock.Lock()
+	defer c.upstreamLock.Unlock()
+	return json.Unmarshal(data, &c.upstream)
+}
 
-	// decode JSON stream
-	decoder := json.NewDecoder(response.Body)
-	errDecoding := decoder.Decode(&obj)
-	if errDecoding != nil {
-		return errDecoding
+// getUpstreamVersion returns the validators NEOS sent for the current export
+// they are empty if the current export has not been sent by NEOS, e.g. after a rollback or a snapshot import
+func (c *Cache) getUpstreamVersion() cms.RepositoryVersion {
+	hash, _, errHash := c.GetContentServerExportHash()
+	c.upstreamLock.Lock()
+	defer c.upstreamLock.Unlock()
+	if errHash != nil || c.upstream.Hash != hash {
+		return cms.RepositoryVersion{}
 	}
+	return c.upstream.RepositoryVersion
+}
+
+// setUpstreamVersion remembers the validators NEOS sent for the export with the given hash
+func (c *Cache) setUpstreamVersion(hash string, version cms.RepositoryVersion) error {
+	c.upstreamLock.Lock()
+	defer c.upstreamLock.Unlock()
+
+	c.upstream = upstreamVersion{Hash: hash, RepositoryVersion: version}
+	if version.ETag == "" && version.LastModified.IsZero() {
+		if err := os.Remove(c.upstreamFilename()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, errMarshal := json.Marshal(c.upstream)
+	if errMarshal != nil {
+		return errMarshal
+	}
+	return ioutil.WriteFile(c.upstreamFilename(), data, 0644)
+}
+
+func (c *Cache) upstreamFilename() string {
+	return c.file + ".upstream"
+}
 
-	// encode JSON stream
-	encoder := json.NewEncoder(file)
-	errEncoding := encoder.Encode(obj)
-	if errEncoding != nil {
-		return errEncoding
+// validateJSONStream reads r until EOF and checks that it contains exactly one well-formed json value
+func validateJSONStream(r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	depth := 0
+	values := 0
+	for {
+		token, errToken := decoder.Token()
+		if errToken == io.EOF {
+			break
+		}
+		if errToken != nil {
+			return errToken
+		}
+		if values > 0 {
+			return errorTrailingData
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		if depth == 0 {
+			values++
+		}
 	}
 
+	if values == 0 {
+		if depth > 0 {
+			return io.ErrUnexpectedEOF
+		}
+		return ErrorEmptyExport
+	}
 	return nil
 }
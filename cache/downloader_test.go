@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/foomo/neosproxy/client/cms"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateJSONStream(t *testing.T) {
+	for input, valid := range map[string]bool{
+		`{"de":{"id":"root","nodes":{"a":{"id":"a","index":[1,2.5,true,null]}}}}`: true,
+		" {}\n":                        true,
+		`"string"`:                     true,
+		`{"de":{"id":"root","nodes":{`: false,
+		`{"de":{"id" "root"}}`:         false,
+		`{"de":{"id":"root"}]`:         false,
+		`{"de":{}} {"en":{}}`:          false,
+		`{"de":{}} garbage`:            false,
+		`<html>internal error</html>`:  false,
+		``:                             false,
+		"\n\t ":                        false,
+	} {
+		err := validateJSONStream(strings.NewReader(input))
+		assert.Equal(t, valid, err == nil, "%q: %v", input, err)
+	}
+	assert.Equal(t, ErrorEmptyExport, validateJSONStream(strings.NewReader("")))
+}
+
+func TestDownloadNeosContentServerExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// large enough to be read in several chunks
+	export := `{"de":{"id":"root","nodes":{` + strings.Repeat(`"a":{"id":"a","data":{"text":"lorem ipsum"}},`, 10000) + `"b":{"id":"b"}}}}`
+	etag := `"v1"`
+	unavailable := 0
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/contentserver/export?workspace=live", r.RequestURI)
+		if unavailable > 0 {
+			unavailable--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(export))
+	}))
+	defer server.Close()
+
+	client, errClient := cms.New(server.URL)
+	assert.NoError(t, errClient)
+
+	c := &Cache{
+		Workspace:   "live",
+		file:        filepath.Join(dir, "export.json"),
+		versionsDir: filepath.Join(dir, "versions"),
+		ctx:         context.Background(),
+		loader:      client.CMS,
+	}
+	filename := c.file + ".download"
+
+	// a temporarily unavailable upstream is retried
+	unavailable = 1
+	hash, version, errDownload := c.downloadNeosContentServerExport(filename)
+	assert.NoError(t, errDownload)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, etag, version.ETag)
+
+	data, errRead := ioutil.ReadFile(filename)
+	assert.NoError(t, errRead)
+	assert.Equal(t, export, string(data))
+
+	hashFromFile, errHash := hashFile(filename)
+	assert.NoError(t, errHash)
+	assert.Equal(t, hashFromFile, hash)
+
+	// unchanged upstream
+	_, errPublish := c.publishExportFile(filename, hash)
+	assert.NoError(t, errPublish)
+	assert.NoError(t, c.setUpstreamVersion(hash, version))
+	_, _, errDownload = c.downloadNeosContentServerExport(filename)
+	assert.Equal(t, cms.ErrorNotModified, errDownload)
+
+	// validators survive a restart
+	restarted := &Cache{file: c.file, versionsDir: c.versionsDir}
+	assert.NoError(t, restarted.loadCurrentExport())
+	assert.NoError(t, restarted.loadUpstreamVersion())
+	assert.Equal(t, version, restarted.getUpstreamVersion())
+
+	// but do not apply to another export, e.g. of an imported snapshot
+	c.compressions.Wait()
+	restarted.compressions.Wait()
+	assert.NoError(t, ioutil.WriteFile(c.file, []byte(`{"de":{"id":"imported"}}`), 0644))
+	imported := &Cache{file: c.file, versionsDir: c.versionsDir}
+	assert.NoError(t, imported.loadCurrentExport())
+	assert.NoError(t, imported.loadUpstreamVersion())
+	assert.Equal(t, cms.RepositoryVersion{}, imported.getUpstreamVersion())
+	imported.compressions.Wait()
+
+	// partial files are removed
+	etag = `"v2"`
+	export = export[:len(export)/2]
+	_, _, errDownload = c.downloadNeosContentServerExport(filename)
+	assert.Error(t, errDownload)
+	_, errStat := os.Stat(filename)
+	assert.True(t, os.IsNotExist(errStat))
+
+	// cancelled on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.ctx = ctx
+	_, _, errDownload = c.downloadNeosContentServerExport(filename)
+	assert.Error(t, errDownload)
+	_, errStat = os.Stat(filename)
+	assert.True(t, os.IsNotExist(errStat))
+}
@@ -7,3 +7,21 @@ var ErrorNoNewExort = errors.New("hash match - no new contentserver export")
 
 // ErrorFileNotExists in case the contentserver export file does not yet exist
 var ErrorFileNotExists = errors.New("contentserver export cache file not exists")
+
+// ErrorDimensionNotExists in case the contentserver export does not contain a dimension
+var ErrorDimensionNotExists = errors.New("contentserver export dimension not exists")
+
+// ErrorExportNotInHistory in case a contentserver export is not retained (anymore)
+var ErrorExportNotInHistory = errors.New("contentserver export not in history")
+
+// ErrorExportFrozen in case automatic refreshes of a workspace are stopped
+var ErrorExportFrozen = errors.New("contentserver export frozen")
+
+// ErrorInvalidExport in case a downloaded contentserver export does not pass validation
+var ErrorInvalidExport = errors.New("contentserver export rejected by validation")
+
+// ErrorIndexNotAvailable in case the contentserver export could not be indexed
+var ErrorIndexNotAvailable = errors.New("contentserver export index not available")
+
+// ErrorNodeNotExists in case a node is not part of the contentserver export
+var ErrorNodeNotExists = errors.New("contentserver export node not exists")
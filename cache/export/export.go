@@ -0,0 +1,156 @@
+// Package export reads contentserver exports and compares them
+//
+// an export is a JSON object of dimension => root node, every node contains
+// its children in "nodes" (id => node) and their order in "index"
+package export
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// Node of a contentserver export without its child nodes
+type Node struct {
+	ID       string          `json:"id"`
+	ParentID string          `json:"parentId,omitempty"`
+	Node     json.RawMessage `json:"node"` // all node properties but "nodes"
+
+	fingerprint string
+}
+
+// Nodes of an export: dimension => id => node, used to compare exports
+type Nodes map[string]map[string]*Node
+
+// DimensionDelta lists the differences of two exports within a dimension
+type DimensionDelta struct {
+	Added   []*Node  `json:"added"`
+	Changed []*Node  `json:"changed"`
+	Removed []string `json:"removed"`
+}
+
+//------------------------------------------------------------------
+// ~ CONSTANTS / VARS
+//------------------------------------------------------------------
+
+// ErrorInvalidExport error in case an export can not be parsed
+var ErrorInvalidExport = errors.New("invalid contentserver export")
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+// Read all nodes of an export including their properties, memory grows with the export
+func Read(r io.Reader) (nodes Nodes, err error) {
+	dimensions := map[string]json.RawMessage{}
+	if err = json.NewDecoder(r).Decode(&dimensions); err != nil {
+		return
+	}
+
+	nodes = Nodes{}
+	for dimension, root := range dimensions {
+		nodes[dimension] = map[string]*Node{}
+		if err = flatten(root, "", nodes[dimension]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Diff returns the differences between two exports per dimension, nodes are sorted by id
+func Diff(from Nodes, to Nodes) map[string]DimensionDelta {
+	deltas := map[string]DimensionDelta{}
+
+	dimensions := map[string]bool{}
+	for dimension := range from {
+		dimensions[dimension] = true
+	}
+	for dimension := range to {
+		dimensions[dimension] = true
+	}
+
+	for dimension := range dimensions {
+		delta := DimensionDelta{
+			Added:   []*Node{},
+			Changed: []*Node{},
+			Removed: []string{},
+		}
+		for id, node := range to[dimension] {
+			previous, ok := from[dimension][id]
+			switch {
+			case !ok:
+				delta.Added = append(delta.Added, node)
+			case previous.fingerprint != node.fingerprint || previous.ParentID != node.ParentID:
+				delta.Changed = append(delta.Changed, node)
+			}
+		}
+		for id := range from[dimension] {
+			if _, ok := to[dimension][id]; !ok {
+				delta.Removed = append(delta.Removed, id)
+			}
+		}
+
+		sort.Slice(delta.Added, func(i, j int) bool { return delta.Added[i].ID < delta.Added[j].ID })
+		sort.Slice(delta.Changed, func(i, j int) bool { return delta.Changed[i].ID < delta.Changed[j].ID })
+		sort.Strings(delta.Removed)
+		deltas[dimension] = delta
+	}
+	return deltas
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+// flatten a node and all of its children into nodes
+func flatten(raw json.RawMessage, parentID string, nodes map[string]*Node) error {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+
+	properties := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &properties); err != nil {
+		return err
+	}
+
+	id := ""
+	if err := json.Unmarshal(properties["id"], &id); err != nil || id == "" {
+		return ErrorInvalidExport
+	}
+
+	children := map[string]json.RawMessage{}
+	if raw, ok := properties["nodes"]; ok && !bytes.Equal(raw, []byte("null")) {
+		if err := json.Unmarshal(raw, &children); err != nil {
+			return err
+		}
+	}
+	delete(properties, "nodes")
+
+	// keys of maps are sorted, the encoding is stable
+	node, errMarshal := json.Marshal(properties)
+	if errMarshal != nil {
+		return errMarshal
+	}
+	sum := md5.Sum(node)
+	nodes[id] = &Node{
+		ID:          id,
+		ParentID:    parentID,
+		Node:        node,
+		fingerprint: hex.EncodeToString(sum[:]),
+	}
+
+	for _, child := range children {
+		if err := flatten(child, id, nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const exportOld = `{
+	"de": {"id": "root", "URI": "/", "index": ["a", "b"], "nodes": {
+		"a": {"id": "a", "URI": "/a", "name": "A", "index": [], "nodes": {}},
+		"b": {"id": "b", "URI": "/b", "name": "B", "index": ["c"], "nodes": {
+			"c": {"id": "c", "URI": "/b/c", "name": "C", "nodes": null}
+		}}
+	}},
+	"en": {"id": "root", "URI": "/", "nodes": {}}
+}`
+
+const exportNew = `{
+	"de": {"id": "root", "URI": "/", "index": ["a", "d"], "nodes": {
+		"a": {"name": "A", "URI": "/a", "id": "a", "index": [], "nodes": {
+			"c": {"id": "c", "URI": "/a/c", "name": "C", "nodes": null}
+		}},
+		"d": {"id": "d", "URI": "/d", "name": "D", "index": [], "nodes": {}}
+	}},
+	"en": {"id": "root", "URI": "/", "nodes": {}}
+}`
+
+func TestRead(t *testing.T) {
+	nodes, err := Read(strings.NewReader(exportOld))
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 2)
+	assert.Len(t, nodes["de"], 4)
+	assert.Equal(t, "b", nodes["de"]["c"].ParentID)
+	assert.NotContains(t, string(nodes["de"]["b"].Node), "nodes")
+	assert.Contains(t, string(nodes["de"]["b"].Node), `"index":["c"]`)
+
+	_, err = Read(strings.NewReader(`{"de": {"URI": "/"}}`))
+	assert.Equal(t, ErrorInvalidExport, err)
+}
+
+func TestDiff(t *testing.T) {
+	from, errFrom := Read(strings.NewReader(exportOld))
+	assert.NoError(t, errFrom)
+	to, errTo := Read(strings.NewReader(exportNew))
+	assert.NoError(t, errTo)
+
+	deltas := Diff(from, to)
+	assert.Len(t, deltas, 2)
+
+	de := deltas["de"]
+	assert.Len(t, de.Added, 1)
+	assert.Equal(t, "d", de.Added[0].ID)
+	assert.Equal(t, []string{"b"}, de.Removed)
+
+	// root: index changed, c: moved, a: property order does not matter
+	changed := []string{}
+	for _, node := range de.Changed {
+		changed = append(changed, node.ID)
+	}
+	assert.Equal(t, []string{"c", "root"}, changed)
+
+	en := deltas["en"]
+	assert.Empty(t, en.Added)
+	assert.Empty(t, en.Changed)
+	assert.Empty(t, en.Removed)
+
+	assert.Empty(t, Diff(to, to)["de"].Changed)
+}
@@ -0,0 +1,342 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// Index of the nodes of an export for lookups by id and uri, it must not be modified
+type Index struct {
+	nodes    map[string]map[string]*IndexedNode // dimension => id => node
+	children map[string]map[string][]string     // dimension => parent id => child ids in index order
+	uris     map[string]map[string]string       // dimension => uri => id
+}
+
+// IndexedNode of an export, all other node properties are not retained
+type IndexedNode struct {
+	ID       string `json:"id"`
+	ParentID string `json:"parentId,omitempty"`
+	URI      string `json:"uri,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// NodeSummary of a node
+type NodeSummary struct {
+	ID   string `json:"id"`
+	URI  string `json:"uri,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// NodeDetails of a node with its parents and children
+type NodeDetails struct {
+	Dimension string        `json:"dimension"`
+	Node      *IndexedNode  `json:"node"`
+	Parents   []NodeSummary `json:"parents"`  // root first
+	Children  []NodeSummary `json:"children"` // in index order
+}
+
+// scanner indexes the nodes of a dimension while walking the tokens of an export
+type scanner struct {
+	decoder  *json.Decoder
+	nodes    map[string]*IndexedNode
+	children map[string][]string
+	uris     map[string]string
+}
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+// Scan indexes the nodes of an export in a single pass, one token at a time
+// only id, parent, children, uri and name of the nodes are retained, all other properties are skipped
+// visit is called with the raw root node of every dimension once it has been scanned, it may be nil
+func Scan(r io.ReaderAt, visit func(dimension string, root io.Reader) error) (index *Index, err error) {
+	decoder := json.NewDecoder(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err = expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	index = &Index{
+		nodes:    map[string]map[string]*IndexedNode{},
+		children: map[string]map[string][]string{},
+		uris:     map[string]map[string]string{},
+	}
+	for decoder.More() {
+		token, errToken := decoder.Token()
+		if errToken != nil {
+			return nil, errToken
+		}
+		dimension, ok := token.(string)
+		if !ok {
+			return nil, ErrorInvalidExport
+		}
+
+		s := &scanner{
+			decoder:  decoder,
+			nodes:    map[string]*IndexedNode{},
+			children: map[string][]string{},
+			uris:     map[string]string{},
+		}
+		start := decoder.InputOffset()
+		if _, err = s.node(); err != nil {
+			return nil, err
+		}
+		index.nodes[dimension] = s.nodes
+		index.children[dimension] = s.children
+		index.uris[dimension] = s.uris
+
+		if visit != nil {
+			if start, err = valueStart(r, start); err != nil {
+				return nil, err
+			}
+			if err = visit(dimension, io.NewSectionReader(r, start, decoder.InputOffset()-start)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = expectDelim(decoder, '}'); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Node returns a node with its parents and children
+func (i *Index) Node(dimension string, id string) (details NodeDetails, ok bool) {
+	node, ok := i.nodes[dimension][id]
+	if !ok {
+		return
+	}
+
+	details = NodeDetails{
+		Dimension: dimension,
+		Node:      node,
+		Parents:   []NodeSummary{},
+		Children:  []NodeSummary{},
+	}
+	for parent, found := i.nodes[dimension][node.ParentID]; found; parent, found = i.nodes[dimension][parent.ParentID] {
+		details.Parents = append([]NodeSummary{parent.summary()}, details.Parents...)
+		if len(details.Parents) > len(i.nodes[dimension]) {
+			break
+		}
+	}
+	for _, childID := range i.children[dimension][id] {
+		details.Children = append(details.Children, i.nodes[dimension][childID].summary())
+	}
+	return
+}
+
+// Count returns the number of indexed nodes of all dimensions
+func (i *Index) Count() (count int) {
+	for _, nodes := range i.nodes {
+		count += len(nodes)
+	}
+	return
+}
+
+// Resolve returns the node of an uri, all dimensions are searched in alphabetical order if dimension is empty
+func (i *Index) Resolve(uri string, dimension string) (node NodeSummary, nodeDimension string, ok bool) {
+	dimensions := []string{dimension}
+	if dimension == "" {
+		dimensions = make([]string, 0, len(i.uris))
+		for dimension := range i.uris {
+			dimensions = append(dimensions, dimension)
+		}
+		sort.Strings(dimensions)
+	}
+
+	uri = normalizeURI(uri)
+	for _, dimension := range dimensions {
+		if id, found := i.uris[dimension][uri]; found {
+			return i.nodes[dimension][id].summary(), dimension, true
+		}
+	}
+	return
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+func (n *IndexedNode) summary() NodeSummary {
+	return NodeSummary{
+		ID:   n.ID,
+		URI:  n.URI,
+		Name: n.Name,
+	}
+}
+
+// node scans a node and its children, the id of the node is returned, empty for null
+func (s *scanner) node() (id string, err error) {
+	token, err := s.decoder.Token()
+	if err != nil || token == nil {
+		return "", err
+	}
+	if token != json.Delim('{') {
+		return "", ErrorInvalidExport
+	}
+
+	node := &IndexedNode{}
+	index := []string{}
+	children := []string{}
+	for s.decoder.More() {
+		key, errKey := s.decoder.Token()
+		if errKey != nil {
+			return "", errKey
+		}
+		switch key {
+		case "id":
+			if errID := s.decoder.Decode(&node.ID); errID != nil {
+				return "", ErrorInvalidExport
+			}
+		case "URI":
+			err = s.decodeOptional(&node.URI)
+		case "name":
+			err = s.decodeOptional(&node.Name)
+		case "index":
+			err = s.decodeOptional(&index)
+		case "nodes":
+			children, err = s.childNodes()
+		default:
+			err = s.skip()
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if err = expectDelim(s.decoder, '}'); err != nil {
+		return "", err
+	}
+	if node.ID == "" {
+		return "", ErrorInvalidExport
+	}
+
+	// the id may follow the children
+	for _, childID := range children {
+		s.nodes[childID].ParentID = node.ID
+	}
+	if len(children) > 0 {
+		sortChildren(children, index)
+		s.children[node.ID] = children
+	}
+	if node.URI != "" {
+		s.uris[normalizeURI(node.URI)] = node.ID
+	}
+	s.nodes[node.ID] = node
+	return node.ID, nil
+}
+
+// childNodes scans the "nodes" of a node and returns the ids of the children
+func (s *scanner) childNodes() (ids []string, err error) {
+	token, err := s.decoder.Token()
+	if err != nil || token == nil {
+		return nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, ErrorInvalidExport
+	}
+	for s.decoder.More() {
+		if _, err = s.decoder.Token(); err != nil {
+			return nil, err
+		}
+		id, errNode := s.node()
+		if errNode != nil {
+			return nil, errNode
+		}
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, expectDelim(s.decoder, '}')
+}
+
+// decodeOptional decodes an optional property, values of another type are ignored
+func (s *scanner) decodeOptional(v interface{}) error {
+	err := s.decoder.Decode(v)
+	if _, ok := err.(*json.UnmarshalTypeError); ok {
+		return nil
+	}
+	return err
+}
+
+// skip a value without decoding it
+func (s *scanner) skip() error {
+	depth := 0
+	for {
+		token, err := s.decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return ErrorInvalidExport
+	}
+	return nil
+}
+
+// valueStart skips the colon and white space between a key and its value
+func valueStart(r io.ReaderAt, offset int64) (int64, error) {
+	b := make([]byte, 1)
+	for {
+		if _, err := r.ReadAt(b, offset); err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ':', ' ', '\t', '\r', '\n':
+			offset++
+		default:
+			return offset, nil
+		}
+	}
+}
+
+// sortChildren in the order of the index, children missing in the index follow sorted by id
+func sortChildren(ids []string, index []string) {
+	positions := make(map[string]int, len(index))
+	for position, id := range index {
+		positions[id] = position
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		positionA, indexedA := positions[ids[a]]
+		positionB, indexedB := positions[ids[b]]
+		switch {
+		case indexedA && indexedB:
+			return positionA < positionB
+		case indexedA != indexedB:
+			return indexedA
+		}
+		return ids[a] < ids[b]
+	})
+}
+
+// normalizeURI strips trailing slashes, except of the root
+func normalizeURI(uri string) string {
+	uri = strings.TrimSpace(uri)
+	if trimmed := strings.TrimRight(uri, "/"); trimmed != "" {
+		return trimmed
+	}
+	return uri
+}
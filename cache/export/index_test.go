@@ -0,0 +1,96 @@
+package export
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndex(t *testing.T) {
+	index, err := Scan(strings.NewReader(`{
+		"de": {"id": "root", "URI": "/de", "name": "Home", "index": ["b", "a"], "nodes": {
+			"a": {"id": "a", "URI": "/de/a", "name": "A", "nodes": {}},
+			"b": {"id": "b", "URI": "/de/b", "name": "B", "index": ["c"], "nodes": {
+				"c": {"id": "c", "URI": "/de/b/c", "name": "C", "nodes": null}
+			}},
+			"z": {"id": "z", "URI": "/de/z", "name": "Z"}
+		}},
+		"en": {"nodes": {
+			"a": {"id": "a", "URI": "/en/a", "name": "A", "properties": {"index": ["ignored"], "nodes": [{"id": "x"}]}}
+		}, "URI": "/en", "id": "root"}
+	}`), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, index.Count())
+
+	details, ok := index.Node("de", "c")
+	assert.True(t, ok)
+	assert.Equal(t, "de", details.Dimension)
+	assert.Equal(t, &IndexedNode{ID: "c", ParentID: "b", URI: "/de/b/c", Name: "C"}, details.Node)
+	assert.Equal(t, []NodeSummary{{ID: "root", URI: "/de", Name: "Home"}, {ID: "b", URI: "/de/b", Name: "B"}}, details.Parents)
+	assert.Empty(t, details.Children)
+
+	// children in index order, unindexed children last
+	details, ok = index.Node("de", "root")
+	assert.True(t, ok)
+	assert.Empty(t, details.Parents)
+	assert.Equal(t, []string{"b", "a", "z"}, []string{details.Children[0].ID, details.Children[1].ID, details.Children[2].ID})
+
+	_, ok = index.Node("de", "unknown")
+	assert.False(t, ok)
+	_, ok = index.Node("fr", "a")
+	assert.False(t, ok)
+
+	node, dimension, ok := index.Resolve("/de/b/c/", "")
+	assert.True(t, ok)
+	assert.Equal(t, "de", dimension)
+	assert.Equal(t, "c", node.ID)
+
+	node, dimension, ok = index.Resolve("/en/a", "en")
+	assert.True(t, ok)
+	assert.Equal(t, "en", dimension)
+	assert.Equal(t, NodeSummary{ID: "a", URI: "/en/a", Name: "A"}, node)
+
+	// the id of a parent may follow its children
+	details, _ = index.Node("en", "a")
+	assert.Equal(t, []NodeSummary{{ID: "root", URI: "/en"}}, details.Parents)
+
+	_, _, ok = index.Resolve("/en/a", "de")
+	assert.False(t, ok)
+	_, _, ok = index.Resolve("/fr", "")
+	assert.False(t, ok)
+}
+
+func TestScan(t *testing.T) {
+	roots := map[string]string{}
+	index, err := Scan(strings.NewReader(exportOld), func(dimension string, root io.Reader) error {
+		data, errRead := ioutil.ReadAll(root)
+		roots[dimension] = string(data)
+		return errRead
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, index.Count())
+	assert.Len(t, roots, 2)
+	assert.Equal(t, `{"id": "root", "URI": "/", "nodes": {}}`, roots["en"])
+
+	errVisit := errors.New("visit failed")
+	_, err = Scan(strings.NewReader(exportOld), func(dimension string, root io.Reader) error {
+		return errVisit
+	})
+	assert.Equal(t, errVisit, err)
+
+	for _, invalid := range []string{
+		`[]`,
+		`{"de": {"id": "root"}`,
+		`{"de": {"URI": "/"}}`,
+		`{"de": []}`,
+		`{"de": {"id": 1}}`,
+		`{"de": {"id": "root", "nodes": []}}`,
+	} {
+		_, err = Scan(strings.NewReader(invalid), nil)
+		assert.Error(t, err, invalid)
+	}
+}
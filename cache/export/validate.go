@@ -0,0 +1,121 @@
+package export
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/foomo/neosproxy/config"
+	"github.com/foomo/neosproxy/model"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// maxSchemaMessages limits the reported schema violations
+const maxSchemaMessages = 20
+
+// Rules a contentserver export has to pass
+type Rules struct {
+	Schema     *gojsonschema.Schema
+	Dimensions []string // required dimensions
+	MinNodes   int      // minimum number of nodes of all dimensions
+	MaxShrink  float64  // maximum percentage of nodes removed since the previous export (0 === disabled)
+}
+
+// NewRules creates validation rules from config
+func NewRules(validation config.ExportValidation, dimensions []string) (rules *Rules, err error) {
+	rules = &Rules{
+		Dimensions: dimensions,
+		MinNodes:   validation.MinNodes,
+		MaxShrink:  validation.MaxShrink,
+	}
+	if validation.Schema != "" {
+		schemaFilename, errAbs := filepath.Abs(validation.Schema)
+		if errAbs != nil {
+			return nil, errAbs
+		}
+		rules.Schema, err = gojsonschema.NewSchema(gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(schemaFilename)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// ValidateSchema validates an export file against the json schema, if there is one
+func (r *Rules) ValidateSchema(filename string) (messages []model.Message) {
+	messages = []model.Message{}
+	if r.Schema == nil {
+		return
+	}
+
+	absFilename, errAbs := filepath.Abs(filename)
+	if errAbs != nil {
+		messages = append(messages, errorMessage("json schema validation failed: "+errAbs.Error(), nil))
+		return
+	}
+	result, errSchema := r.Schema.Validate(gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(absFilename)))
+	if errSchema != nil {
+		messages = append(messages, errorMessage("json schema validation failed: "+errSchema.Error(), nil))
+		return
+	}
+	for i, resultError := range result.Errors() {
+		if i == maxSchemaMessages {
+			messages = append(messages, errorMessage(fmt.Sprintf("%d more json schema violations", len(result.Errors())-i), nil))
+			break
+		}
+		messages = append(messages, errorMessage(resultError.Description(), map[string]string{"field": resultError.Field()}))
+	}
+	return
+}
+
+// ValidateIndex validates the indexed nodes of an export, previous is the number of nodes of the previous export (0 === unknown)
+// the export is valid if there are no messages with status error
+func (r *Rules) ValidateIndex(index *Index, previous int) (messages []model.Message) {
+	messages = []model.Message{}
+
+	for _, dimension := range r.Dimensions {
+		if _, ok := index.nodes[dimension]; !ok {
+			messages = append(messages, errorMessage("missing dimension", map[string]string{"dimension": dimension}))
+		}
+	}
+
+	count := index.Count()
+	if count < r.MinNodes {
+		messages = append(messages, errorMessage(fmt.Sprintf("%d nodes, at least %d nodes required", count, r.MinNodes), nil))
+	}
+
+	if r.MaxShrink > 0 && previous > 0 && count < previous {
+		shrink := float64(previous-count) / float64(previous) * 100
+		if shrink > r.MaxShrink {
+			messages = append(messages, errorMessage(fmt.Sprintf("export shrank by %.1f%% from %d to %d nodes, at most %.1f%% allowed", shrink, previous, count, r.MaxShrink), nil))
+		}
+	}
+
+	messages = append(messages, model.Message{
+		Status:  model.MessageStatusInfo,
+		Message: fmt.Sprintf("%d nodes in %d dimensions", count, len(index.nodes)),
+	})
+	return
+}
+
+// ReadErrorMessage reports an export that can not be read
+func ReadErrorMessage(err error) model.Message {
+	return errorMessage("invalid contentserver export: "+err.Error(), nil)
+}
+
+// Valid is false if any message has status error
+func Valid(messages []model.Message) bool {
+	for _, message := range messages {
+		if message.Status == model.MessageStatusError {
+			return false
+		}
+	}
+	return true
+}
+
+func errorMessage(message string, data map[string]string) model.Message {
+	return model.Message{
+		Status:  model.MessageStatusError,
+		Message: message,
+		Data:    data,
+	}
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/foomo/neosproxy/config"
+	"github.com/foomo/neosproxy/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	schema := filepath.Join(dir, "schema.json")
+	assert.NoError(t, ioutil.WriteFile(schema, []byte(`{
+		"type": "object",
+		"additionalProperties": {"type": "object", "required": ["id"]}
+	}`), 0644))
+
+	rules, errRules := NewRules(config.ExportValidation{
+		Schema:    schema,
+		MinNodes:  3,
+		MaxShrink: 50,
+	}, []string{"de", "en"})
+	assert.NoError(t, errRules)
+
+	validate := func(export string, previous int) []model.Message {
+		filename := filepath.Join(dir, "export.json")
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(export), 0644))
+		messages := rules.ValidateSchema(filename)
+		if !Valid(messages) {
+			return messages
+		}
+		index, errScan := Scan(strings.NewReader(export), nil)
+		if errScan != nil {
+			return append(messages, ReadErrorMessage(errScan))
+		}
+		return append(messages, rules.ValidateIndex(index, previous)...)
+	}
+
+	errors := func(messages []model.Message) (errors []string) {
+		for _, message := range messages {
+			if message.Status == model.MessageStatusError {
+				errors = append(errors, message.Message)
+			}
+		}
+		return
+	}
+
+	// valid
+	messages := validate(exportOld, 4)
+	assert.True(t, Valid(messages))
+	assert.Equal(t, "5 nodes in 2 dimensions", messages[len(messages)-1].Message)
+
+	// error page rendered as json
+	messages = validate(`{"error": "internal server error"}`, 5)
+	assert.False(t, Valid(messages))
+	assert.Len(t, errors(messages), 1)
+
+	// truncated
+	messages = validate(`{"de": {"id": "root", "nodes": {`, 5)
+	assert.False(t, Valid(messages))
+
+	// missing dimension, too few nodes, shrank too much
+	messages = validate(`{"de": {"id": "root", "nodes": {"a": {"id": "a"}}}}`, 5)
+	assert.Equal(t, []string{
+		"missing dimension",
+		"2 nodes, at least 3 nodes required",
+		"export shrank by 60.0% from 5 to 2 nodes, at most 50.0% allowed",
+	}, errors(messages))
+
+	// unknown previous export
+	messages = validate(`{"de": {"id": "root", "nodes": {"a": {"id": "a"}}}, "en": {"id": "root"}}`, 0)
+	assert.True(t, Valid(messages))
+
+	_, errRules = NewRules(config.ExportValidation{Schema: filepath.Join(dir, "missing.json")}, nil)
+	assert.Error(t, errRules)
+}
@@ -5,13 +5,14 @@ import (
 	"encoding/hex"
 	"io"
 	"os"
+
+	"github.com/foomo/neosproxy/utils"
 )
 
-func (c *Cache) fileNotExists() bool {
-	if _, err := os.Stat(c.file); os.IsNotExist(err) {
-		return true
-	}
-	return false
+// compressedExtensions of pre-compressed contentserver exports
+var compressedExtensions = map[string]string{
+	utils.EncodingGzip:   ".gz",
+	utils.EncodingBrotli: ".br",
 }
 
 // hashFile calculates a md5 hash sum of a given file
@@ -33,3 +34,54 @@ func hashFile(filename string) (hash string, err error) {
 	hash = hex.EncodeToString(hasher.Sum(nil))
 	return
 }
+
+// compressedFilename returns the filename of a compressed variant of a file
+func compressedFilename(filename string, encoding string) string {
+	return filename + compressedExtensions[encoding]
+}
+
+// compressFile writes all compressed variants of a file next to it
+// returns encoding => filename of the variants
+func compressFile(filename string) (compressed map[string]string, err error) {
+	compressed = map[string]string{}
+	for _, encoding := range utils.Encodings {
+		target := compressedFilename(filename, encoding)
+		if err = compressFileTo(filename, target, encoding); err != nil {
+			for _, filename := range compressed {
+				os.Remove(filename)
+			}
+			compressed = nil
+			return
+		}
+		compressed[encoding] = target
+	}
+	return
+}
+
+func compressFileTo(filename string, target string, encoding string) error {
+	source, errOpen := os.Open(filename)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer source.Close()
+
+	file, errCreate := os.Create(target)
+	if errCreate != nil {
+		return errCreate
+	}
+
+	compressor, errCompressor := utils.NewCompressor(encoding, file, utils.CompressionBest)
+	if errCompressor == nil {
+		_, errCompressor = io.Copy(compressor, source)
+		if errClose := compressor.Close(); errCompressor == nil {
+			errCompressor = errClose
+		}
+	}
+	if errClose := file.Close(); errCompressor == nil {
+		errCompressor = errClose
+	}
+	if errCompressor != nil {
+		os.Remove(target)
+	}
+	return errCompressor
+}
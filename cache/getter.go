@@ -2,31 +2,47 @@ package cache
 
 import (
 	"os"
+
+	"github.com/foomo/neosproxy/model"
 )
 
-// GetContentServerExport will return a contentserver export json
-// hint: close file after reading
-// hint: lock file read access
-func (c *Cache) GetContentServerExport() (file *os.File, fileInfo os.FileInfo, err error) {
+// GetContentServerExportHash will return the md5 hash and file info of the current contentserver export
+// ErrorFileNotExists will be returned if there is no export yet
+func (c *Cache) GetContentServerExportHash() (hash string, fileInfo os.FileInfo, err error) {
+	c.versionLock.Lock()
+	defer c.versionLock.Unlock()
 
-	if c.fileNotExists() {
+	if c.current == nil {
 		err = ErrorFileNotExists
 		return
 	}
+	hash = c.current.hash
+	fileInfo = c.current.fileInfo
+	return
+}
 
-	var errFileInfo error
-	fileInfo, errFileInfo = os.Stat(c.file)
-	if errFileInfo != nil {
-		err = errFileInfo
-		return
+// Status of the contentserver export
+func (c *Cache) Status() model.ExportStatus {
+	status := model.ExportStatus{}
+	if hash, fileInfo, errHash := c.GetContentServerExportHash(); errHash == nil {
+		status.Hash = hash
+		status.Updated = fileInfo.ModTime()
 	}
-
-	file, errOpenFile := os.Open(c.file)
-	if errOpenFile != nil {
-		err = errOpenFile
-		return
+	c.historyLock.RLock()
+	status.Versions = len(c.history)
+	c.historyLock.RUnlock()
+	status.Frozen, status.FrozenSince = c.Frozen()
+	status.Ready = c.IsReady()
+	c.refreshLock.Lock()
+	status.PendingRefreshes = c.refresh.pending
+	if c.refresh.pending > 0 {
+		status.PendingSince = c.refresh.firstRequest
 	}
-	// defer file.Close()
-
-	return
+	status.Refreshing = c.refresh.running
+	status.SkippedRefreshes = c.refresh.skipped
+	c.refreshLock.Unlock()
+	load := c.loadStatus()
+	status.LastError = load.err
+	status.LastErrorAt = load.at
+	return status
 }
@@ -0,0 +1,240 @@
+package cache
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/foomo/neosproxy/cache/export"
+	"github.com/foomo/neosproxy/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// maxCachedDeltas limits the number of calculated deltas kept in memory
+const maxCachedDeltas = 16
+
+// ExportHistoryDirectory returns the directory of retained contentserver exports of a workspace
+func ExportHistoryDirectory(cacheDirectory string, workspace string) string {
+	return filepath.Join(ExportDirectory(cacheDirectory), "history", workspace)
+}
+
+// GetExportHistory returns all retained exports, oldest first
+func (c *Cache) GetExportHistory() []ExportVersion {
+	c.historyLock.RLock()
+	defer c.historyLock.RUnlock()
+	history := make([]ExportVersion, len(c.history))
+	copy(history, c.history)
+	return history
+}
+
+// GetExportDelta returns the nodes added, removed or changed since a retained export
+// ErrorExportNotInHistory will be returned if the export is unknown, consumers have to reload the full export
+func (c *Cache) GetExportDelta(since string) (delta *ExportDelta, err error) {
+
+	to, _, errHash := c.GetContentServerExportHash()
+	if errHash != nil {
+		err = errHash
+		return
+	}
+
+	if since == to {
+		delta = &ExportDelta{
+			Workspace:  c.Workspace,
+			From:       since,
+			To:         to,
+			Dimensions: map[string]export.DimensionDelta{},
+		}
+		return
+	}
+
+	c.historyLock.RLock()
+	from, okFrom := c.historyVersion(since)
+	_, okTo := c.historyVersion(to)
+	if cached, ok := c.deltas[since+to]; ok {
+		c.historyLock.RUnlock()
+		delta = cached
+		return
+	}
+	c.historyLock.RUnlock()
+
+	if !okFrom || !okTo {
+		err = ErrorExportNotInHistory
+		return
+	}
+
+	nodesFrom, errFrom := c.readHistoryExport(from.Hash)
+	if errFrom != nil {
+		err = historyReadError(errFrom)
+		return
+	}
+	nodesTo, errTo := c.readHistoryExport(to)
+	if errTo != nil {
+		err = historyReadError(errTo)
+		return
+	}
+
+	delta = &ExportDelta{
+		Workspace:  c.Workspace,
+		From:       since,
+		To:         to,
+		Dimensions: export.Diff(nodesFrom, nodesTo),
+	}
+
+	c.historyLock.Lock()
+	if len(c.deltas) >= maxCachedDeltas {
+		c.deltas = map[string]*ExportDelta{}
+	}
+	c.deltas[since+to] = delta
+	c.historyLock.Unlock()
+	return
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+// loadExportHistory reads retained exports from disk and adds the current export
+func (c *Cache) loadExportHistory() error {
+	if c.historySize < 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.historyDir, 0755); err != nil {
+		return err
+	}
+
+	files, errReadDir := ioutil.ReadDir(c.historyDir)
+	if errReadDir != nil {
+		return errReadDir
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	c.historyLock.Lock()
+	c.history = []ExportVersion{}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		c.history = append(c.history, ExportVersion{
+			Hash:    strings.TrimSuffix(file.Name(), ".json"),
+			Created: file.ModTime(),
+			Size:    file.Size(),
+		})
+	}
+	c.historyLock.Unlock()
+
+	if !c.hasExport() {
+		return nil
+	}
+	hash, _, errHash := c.GetContentServerExportHash()
+	if errHash != nil {
+		return errHash
+	}
+	return c.addExportToHistory(hash)
+}
+
+// addExportToHistory retains the current export and removes the oldest exports exceeding the history size
+func (c *Cache) addExportToHistory(hash string) error {
+	if c.historySize < 0 {
+		return nil
+	}
+
+	c.historyLock.Lock()
+	defer c.historyLock.Unlock()
+
+	// an export seen before becomes the latest one again
+	for i, version := range c.history {
+		if version.Hash == hash {
+			c.history = append(append(c.history[:i:i], c.history[i+1:]...), version)
+			return nil
+		}
+	}
+
+	filename := c.historyFilename(hash)
+	if errLink := os.Link(c.file, filename); errLink != nil && !os.IsExist(errLink) {
+		// hard links are not supported everywhere
+		if errCopy := copyFile(c.file, filename); errCopy != nil {
+			return errCopy
+		}
+	}
+
+	fileInfo, errFileInfo := os.Stat(filename)
+	if errFileInfo != nil {
+		return errFileInfo
+	}
+	c.history = append(c.history, ExportVersion{
+		Hash:    hash,
+		Created: fileInfo.ModTime(),
+		Size:    fileInfo.Size(),
+	})
+
+	for len(c.history) > c.historySize {
+		if errRemove := os.Remove(c.historyFilename(c.history[0].Hash)); errRemove != nil && !os.IsNotExist(errRemove) {
+			logging.GetDefaultLogEntry().WithError(errRemove).WithFields(logrus.Fields{
+				logging.FieldWorkspace: c.Workspace,
+				"hash":                 c.history[0].Hash,
+			}).Warn("failed removing contentserver export from history")
+		}
+		c.history = c.history[1:]
+	}
+	c.deltas = map[string]*ExportDelta{}
+	return nil
+}
+
+// readHistoryExport reads all nodes of a retained export for a delta
+func (c *Cache) readHistoryExport(hash string) (export.Nodes, error) {
+	file, errOpen := os.Open(c.historyFilename(hash))
+	if errOpen != nil {
+		return nil, errOpen
+	}
+	defer file.Close()
+	return export.Read(file)
+}
+
+// historyVersion looks up a retained export, lock history read access
+func (c *Cache) historyVersion(hash string) (version ExportVersion, ok bool) {
+	for _, version = range c.history {
+		if version.Hash == hash {
+			return version, true
+		}
+	}
+	return ExportVersion{}, false
+}
+
+func (c *Cache) historyFilename(hash string) string {
+	return filepath.Join(c.historyDir, hash+".json")
+}
+
+// historyReadError maps exports removed from history in the meantime
+func historyReadError(err error) error {
+	if os.IsNotExist(err) {
+		return ErrorExportNotInHistory
+	}
+	return err
+}
+
+func copyFile(filename string, target string) error {
+	source, errOpen := os.Open(filename)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer source.Close()
+
+	file, errCreate := os.Create(target)
+	if errCreate != nil {
+		return errCreate
+	}
+	_, errCopy := io.Copy(file, source)
+	if errClose := file.Close(); errCopy == nil {
+		errCopy = errClose
+	}
+	if errCopy != nil {
+		os.Remove(target)
+	}
+	return errCopy
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &Cache{
+		Workspace:   "live",
+		file:        filepath.Join(dir, "export.json"),
+		versionsDir: filepath.Join(dir, "versions"),
+		historyDir:  filepath.Join(dir, "history"),
+		historySize: 2,
+		deltas:      map[string]*ExportDelta{},
+	}
+	assert.NoError(t, c.loadExportHistory())
+	assert.Empty(t, c.GetExportHistory())
+
+	write := func(export string) string {
+		assert.NoError(t, ioutil.WriteFile(c.file+".download", []byte(export), 0644))
+		hash, errHash := hashFile(c.file + ".download")
+		assert.NoError(t, errHash)
+		_, errPublish := c.publishExportFile(c.file+".download", hash)
+		assert.NoError(t, errPublish)
+		assert.NoError(t, c.addExportToHistory(hash))
+		return hash
+	}
+
+	first := write(`{"de":{"id":"root","index":["a"],"nodes":{"a":{"id":"a"}}}}`)
+	second := write(`{"de":{"id":"root","index":["a","b"],"nodes":{"a":{"id":"a"},"b":{"id":"b"}}}}`)
+
+	delta, errDelta := c.GetExportDelta(first)
+	assert.NoError(t, errDelta)
+	assert.Equal(t, first, delta.From)
+	assert.Equal(t, second, delta.To)
+	assert.Len(t, delta.Dimensions["de"].Added, 1)
+	assert.Len(t, delta.Dimensions["de"].Changed, 1)
+	assert.Empty(t, delta.Dimensions["de"].Removed)
+
+	delta, errDelta = c.GetExportDelta(second)
+	assert.NoError(t, errDelta)
+	assert.Empty(t, delta.Dimensions)
+
+	// oldest export is dropped
+	third := write(`{"de":{"id":"root","index":[],"nodes":{}}}`)
+	history := c.GetExportHistory()
+	assert.Len(t, history, 2)
+	assert.Equal(t, second, history[0].Hash)
+	assert.Equal(t, third, history[1].Hash)
+
+	_, errDelta = c.GetExportDelta(first)
+	assert.Equal(t, ErrorExportNotInHistory, errDelta)
+	_, errStat := os.Stat(c.historyFilename(first))
+	assert.True(t, os.IsNotExist(errStat))
+
+	delta, errDelta = c.GetExportDelta(second)
+	assert.NoError(t, errDelta)
+	assert.Equal(t, []string{"a", "b"}, delta.Dimensions["de"].Removed)
+
+	// history survives a restart
+	restarted := &Cache{
+		Workspace:   "live",
+		file:        c.file,
+		versionsDir: c.versionsDir,
+		historyDir:  c.historyDir,
+		historySize: 2,
+		deltas:      map[string]*ExportDelta{},
+	}
+	assert.NoError(t, restarted.loadCurrentExport())
+	assert.NoError(t, restarted.loadExportHistory())
+	assert.Len(t, restarted.GetExportHistory(), 2)
+	_, errDelta = restarted.GetExportDelta(second)
+	assert.NoError(t, errDelta)
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"github.com/foomo/neosproxy/cache/export"
+)
+
+// GetNode returns a node of the current export with its parents and children and the hash of the export
+// ErrorFileNotExists, ErrorIndexNotAvailable or ErrorNodeNotExists will be returned
+func (c *Cache) GetNode(dimension string, id string) (details export.NodeDetails, hash string, err error) {
+	index, hash, err := c.currentIndex()
+	if err != nil {
+		return
+	}
+	details, ok := index.Node(dimension, id)
+	if !ok {
+		err = ErrorNodeNotExists
+	}
+	return
+}
+
+// ResolveURI returns the node of an uri in the current export and the hash of the export, all dimensions are searched if dimension is empty
+// ErrorFileNotExists, ErrorIndexNotAvailable or ErrorNodeNotExists will be returned
+func (c *Cache) ResolveURI(uri string, dimension string) (node export.NodeSummary, nodeDimension string, hash string, err error) {
+	index, hash, err := c.currentIndex()
+	if err != nil {
+		return
+	}
+	node, nodeDimension, ok := index.Resolve(uri, dimension)
+	if !ok {
+		err = ErrorNodeNotExists
+	}
+	return
+}
+
+func (c *Cache) currentIndex() (index *export.Index, hash string, err error) {
+	c.versionLock.Lock()
+	defer c.versionLock.Unlock()
+
+	if c.current == nil {
+		err = ErrorFileNotExists
+		return
+	}
+	if c.current.index == nil {
+		err = ErrorIndexNotAvailable
+		return
+	}
+	return c.current.index, c.current.hash, nil
+}
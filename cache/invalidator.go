@@ -1,30 +1,45 @@
 package cache
 
 import (
-	"errors"
 	"os"
 	"time"
 
 	"github.com/cloudfoundry/bytefmt"
+	"github.com/foomo/neosproxy/client/cms"
 	"github.com/foomo/neosproxy/logging"
 	"github.com/sirupsen/logrus"
 )
 
-// Invalidate cache maybe invalidates cache, but skips requests if invalidation queue is already full
+// Invalidate requests a refresh of the contentserver export, requests are debounced but never dropped
+// a request arriving during a running refresh triggers another refresh afterwards
+// returns false if the request joined a pending refresh
 func (c *Cache) Invalidate() bool {
 
 	// logger
 	log := logging.GetDefaultLogEntry().WithField(logging.FieldWorkspace, c.Workspace)
 
+	now := time.Now()
+	c.refreshLock.Lock()
+	c.refresh.pending++
+	c.refresh.lastRequest = now
+	pending := c.refresh.pending
+	if pending == 1 {
+		c.refresh.firstRequest = now
+	}
+	c.refreshLock.Unlock()
+
+	// wake up the refresh loop, a signal in the channel will do
 	select {
-	case c.invalidationChannel <- time.Now():
-		log.Info("contentserver export invalidation request added to queue")
-		return true
+	case c.invalidationChannel <- now:
 	default:
-		log.Info("contentserver export invalidation request ignored, queue seems to be full")
-		return false
 	}
 
+	if pending > 1 {
+		log.WithField("pending", pending).Info("contentserver export invalidation request joined a pending refresh")
+		return false
+	}
+	log.Info("contentserver export invalidation request added to queue")
+	return true
 }
 
 // cacheNeosContentServerExport ...
@@ -38,22 +53,20 @@ func (c *Cache) cacheNeosContentServerExport() error {
 	// download new export
 	downloadFilename := c.file + ".download"
 	neosContentServerExportURL := c.neos.URL.String() + "/contentserver/export?workspace=" + c.Workspace
-	if err := downloadNeosContentServerExport(downloadFilename, neosContentServerExportURL); err != nil {
-		return err
+	hashNew, upstream, errDownload := c.downloadNeosContentServerExport(downloadFilename)
+	if errDownload != nil {
+		if errDownload == cms.ErrorNotModified {
+			return ErrorNoNewExort
+		}
+		return errDownload
 	}
 
-	// calc hash of existing file
-	hashOld, errHashOld := hashFile(c.file)
-	if errHashOld != nil {
+	// hash of existing file, cached until it changes
+	hashOld, _, errHashOld := c.GetContentServerExportHash()
+	if errHashOld != nil && errHashOld != ErrorFileNotExists {
 		return errHashOld
 	}
 
-	// calc hash of new file
-	hashNew, errHashNew := hashFile(downloadFilename)
-	if errHashNew != nil {
-		return errHashNew
-	}
-
 	// logging
 	log.WithFields(logrus.Fields{
 		"hashNew": hashNew,
@@ -61,25 +74,49 @@ func (c *Cache) cacheNeosContentServerExport() error {
 	}).Debug("content server export hashed")
 
 	if hashNew == hashOld {
+		os.Remove(downloadFilename)
+		if errUpstream := c.setUpstreamVersion(hashNew, upstream); errUpstream != nil {
+			log.WithError(errUpstream).Warn("failed saving upstream version of contentserver export")
+		}
 		return ErrorNoNewExort
 	}
 
-	if errRename := os.Rename(downloadFilename, c.file); errRename != nil {
-		return errRename
+	// index once for validation, dimension slices and node lookups
+	version, errVersion := c.newVersion(hashNew)
+	if errVersion != nil {
+		os.Remove(downloadFilename)
+		return errVersion
+	}
+	index, errRead := version.read(downloadFilename)
+
+	// validate before replacing the current export
+	report, valid := c.validateExport(downloadFilename, index, errRead, neosContentServerExportURL, hashNew)
+	if !valid {
+		os.Remove(downloadFilename)
+		version.remove()
+		log.WithFields(logrus.Fields{
+			"hash":     hashNew,
+			"messages": report.Messages,
+		}).Error("contentserver export rejected")
+		c.broker.NotifyOnExportRejected(c.Workspace, report)
+		return ErrorInvalidExport
 	}
 
-	fileInfo, errFileInfo := os.Stat(c.file)
-	if errFileInfo != nil {
-		return errFileInfo
+	// not published if frozen while downloading
+	fileInfo, errReplace := c.publishExport(version, downloadFilename, index)
+	if errReplace != nil {
+		os.Remove(downloadFilename)
+		return errReplace
+	}
+	if errUpstream := c.setUpstreamVersion(hashNew, upstream); errUpstream != nil {
+		log.WithError(errUpstream).Warn("failed saving upstream version of contentserver export")
 	}
 
-	// empty file => remove cached file
-	if fileInfo.Size() == 0 {
-		errRemove := os.Remove(c.file)
-		if errRemove != nil {
-			return errRemove
-		}
-		return errors.New("cache: download failed, empty file")
+	c.markReady()
+
+	// retain export for deltas
+	if errHistory := c.addExportToHistory(hashNew); errHistory != nil {
+		log.WithError(errHistory).Warn("failed adding contentserver export to history")
 	}
 
 	// notify broker
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/foomo/neosproxy/client/cms"
+	"github.com/foomo/neosproxy/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// Rollback replaces the current export by a retained one and notifies the broker
+// it is mutually exclusive with publishing refreshes, which are dropped once the export is frozen
+// ErrorExportNotInHistory will be returned if the export is not retained
+func (c *Cache) Rollback(hash string) error {
+
+	start := time.Now()
+
+	// logger
+	log := logging.GetDefaultLogEntry().WithFields(logrus.Fields{
+		logging.FieldWorkspace: c.Workspace,
+		"hash":                 hash,
+	})
+
+	c.historyLock.RLock()
+	_, ok := c.historyVersion(hash)
+	c.historyLock.RUnlock()
+	if !ok {
+		return ErrorExportNotInHistory
+	}
+
+	// refreshes do not publish meanwhile
+	c.publishLock.Lock()
+	defer c.publishLock.Unlock()
+
+	current, _, errHash := c.GetContentServerExportHash()
+	if errHash != nil && errHash != ErrorFileNotExists {
+		return errHash
+	}
+	if current == hash {
+		log.Info("contentserver export rollback skipped, export is the current one")
+		return nil
+	}
+
+	// copy instead of linking, the export gets a new modification time
+	rollbackFilename := c.file + ".rollback"
+	if errCopy := copyFile(c.historyFilename(hash), rollbackFilename); errCopy != nil {
+		return historyReadError(errCopy)
+	}
+
+	if _, errReplace := c.publishExportFile(rollbackFilename, hash); errReplace != nil {
+		os.Remove(rollbackFilename)
+		return errReplace
+	}
+	c.markReady()
+
+	// NEOS did not send the rolled back export, the next refresh must not be conditional
+	if errUpstream := c.setUpstreamVersion("", cms.RepositoryVersion{}); errUpstream != nil {
+		log.WithError(errUpstream).Warn("failed resetting upstream version of contentserver export")
+	}
+
+	if errHistory := c.addExportToHistory(hash); errHistory != nil {
+		log.WithError(errHistory).Warn("failed adding contentserver export to history")
+	}
+
+	// notify broker
+	c.broker.NotifyOnSitemapChange(c.Workspace)
+
+	log.WithDuration(start).WithField("previous", current).Info("rolled back contentserver export")
+	return nil
+}
+
+// Freeze stops automatic refreshes of the export until Unfreeze is called, even across restarts
+func (c *Cache) Freeze() error {
+	c.frozenLock.Lock()
+	defer c.frozenLock.Unlock()
+
+	if !c.frozenAt.IsZero() {
+		return nil
+	}
+
+	frozenAt := time.Now()
+	if err := ioutil.WriteFile(c.frozenFilename(), []byte(frozenAt.Format(time.RFC3339)), 0644); err != nil {
+		return err
+	}
+	c.frozenAt = frozenAt
+
+	logging.GetDefaultLogEntry().WithField(logging.FieldWorkspace, c.Workspace).Info("contentserver export frozen")
+	return nil
+}
+
+// Unfreeze resumes automatic refreshes of the export
+func (c *Cache) Unfreeze() error {
+	c.frozenLock.Lock()
+	defer c.frozenLock.Unlock()
+
+	if err := os.Remove(c.frozenFilename()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	c.frozenAt = time.Time{}
+
+	logging.GetDefaultLogEntry().WithField(logging.FieldWorkspace, c.Workspace).Info("contentserver export unfrozen")
+	return nil
+}
+
+// Frozen returns since when automatic refreshes are stopped, frozen is false otherwise
+func (c *Cache) Frozen() (frozen bool, since time.Time) {
+	c.frozenLock.RLock()
+	defer c.frozenLock.RUnlock()
+	return !c.frozenAt.IsZero(), c.frozenAt
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+// loadFrozen restores the frozen state of a previous run
+func (c *Cache) loadFrozen() error {
+	data, errRead := ioutil.ReadFile(c.frozenFilename())
+	if errRead != nil {
+		if os.IsNotExist(errRead) {
+			return nil
+		}
+		return errRead
+	}
+
+	frozenAt, errParse := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if errParse != nil {
+		// frozen, but we do not know since when
+		frozenAt = time.Unix(0, 0)
+	}
+
+	c.frozenLock.Lock()
+	c.frozenAt = frozenAt
+	c.frozenLock.Unlock()
+	return nil
+}
+
+func (c *Cache) frozenFilename() string {
+	return c.file + ".frozen"
+}
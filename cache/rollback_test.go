@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/client/cms"
+	"github.com/foomo/neosproxy/config"
+	"github.com/foomo/neosproxy/model"
+	"github.com/foomo/neosproxy/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type testBroker struct {
+	notifications int
+	rejections    []model.Report
+}
+
+func (b *testBroker) NotifyOnSitemapChange(workspace string) {
+	b.notifications++
+}
+
+func (b *testBroker) NotifyOnExportRejected(workspace string, report model.Report) {
+	b.rejections = append(b.rejections, report)
+}
+
+func TestRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	broker := &testBroker{}
+	c := &Cache{
+		Workspace:   "live",
+		file:        filepath.Join(dir, "export.json"),
+		versionsDir: filepath.Join(dir, "versions"),
+		historyDir:  filepath.Join(dir, "history"),
+		historySize: 5,
+		deltas:      map[string]*ExportDelta{},
+		broker:      broker,
+		ready:       make(chan struct{}),
+	}
+	assert.NoError(t, c.loadExportHistory())
+
+	write := func(export string) string {
+		assert.NoError(t, ioutil.WriteFile(c.file+".download", []byte(export), 0644))
+		hash, errHash := hashFile(c.file + ".download")
+		assert.NoError(t, errHash)
+		_, errReplace := c.publishExportFile(c.file+".download", hash)
+		assert.NoError(t, errReplace)
+		assert.NoError(t, c.addExportToHistory(hash))
+		return hash
+	}
+
+	good := write(`{"de":{"id":"root","nodes":{"a":{"id":"a"}}}}`)
+	broken := write(`{"de":{"id":"root","nodes":{}}}`)
+
+	assert.Equal(t, ErrorExportNotInHistory, c.Rollback("unknown"))
+	assert.Equal(t, 0, broker.notifications)
+
+	assert.NoError(t, c.Rollback(good))
+	assert.Equal(t, 1, broker.notifications)
+	hash, _, errHash := c.GetContentServerExportHash()
+	assert.NoError(t, errHash)
+	assert.Equal(t, good, hash)
+	data, errRead := ioutil.ReadFile(c.file)
+	assert.NoError(t, errRead)
+	assert.Contains(t, string(data), `"a"`)
+
+	// compressed variants are replaced as well
+	c.compressions.Wait()
+	export, errExport := c.AcquireExport()
+	assert.NoError(t, errExport)
+	assert.Equal(t, good, export.Hash)
+	compressed, errCompressed := export.OpenCompressed("gzip")
+	assert.NoError(t, errCompressed)
+	compressed.Close()
+	export.Release()
+
+	// the rolled back export is the latest one
+	history := c.GetExportHistory()
+	assert.Equal(t, []string{broken, good}, []string{history[0].Hash, history[1].Hash})
+
+	// rolling back to the current export is a no-op
+	assert.NoError(t, c.Rollback(good))
+	assert.Equal(t, 1, broker.notifications)
+}
+
+func TestRollbackRefresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	upstream := `{"de":{"id":"root","nodes":{"a":{"id":"a"}}}}`
+	etag := `"v1"`
+	conditional := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = r.Header.Get("If-None-Match")
+		if conditional == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(upstream))
+	}))
+	defer server.Close()
+	client, errClient := cms.New(server.URL)
+	assert.NoError(t, errClient)
+
+	c := &Cache{
+		Workspace:   "live",
+		file:        filepath.Join(dir, "export.json"),
+		versionsDir: filepath.Join(dir, "versions"),
+		historyDir:  filepath.Join(dir, "history"),
+		historySize: 5,
+		deltas:      map[string]*ExportDelta{},
+		broker:      &testBroker{},
+		neos:        config.Neos{URL: client.Endpoint},
+		ctx:         context.Background(),
+		loader:      client.CMS,
+		ready:       make(chan struct{}),
+	}
+	assert.NoError(t, c.loadExportHistory())
+
+	assert.NoError(t, c.cacheNeosContentServerExport())
+	first, _, _ := c.GetContentServerExportHash()
+
+	upstream = `{"de":{"id":"root","nodes":{"b":{"id":"b"}}}}`
+	etag = `"v2"`
+	assert.NoError(t, c.cacheNeosContentServerExport())
+	second, _, _ := c.GetContentServerExportHash()
+	assert.Equal(t, ErrorNoNewExort, c.cacheNeosContentServerExport())
+	assert.Equal(t, etag, conditional)
+
+	// NEOS did not send the rolled back export, it is replaced by the next refresh
+	assert.NoError(t, c.Rollback(first))
+	assert.NoError(t, c.cacheNeosContentServerExport())
+	assert.Empty(t, conditional)
+	current, _, _ := c.GetContentServerExportHash()
+	assert.Equal(t, second, current)
+	c.compressions.Wait()
+}
+
+func TestRollbackDuringRefresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	upstream := `{"de":{"id":"root","nodes":{"a":{"id":"a"}}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(upstream))
+	}))
+	defer server.Close()
+	client, errClient := cms.New(server.URL)
+	assert.NoError(t, errClient)
+
+	c := &Cache{
+		Workspace:   "live",
+		file:        filepath.Join(dir, "export.json"),
+		versionsDir: filepath.Join(dir, "versions"),
+		historyDir:  filepath.Join(dir, "history"),
+		historySize: 5,
+		deltas:      map[string]*ExportDelta{},
+		broker:      &testBroker{},
+		neos:        config.Neos{URL: client.Endpoint},
+		ctx:         context.Background(),
+		loader:      client.CMS,
+		ready:       make(chan struct{}),
+	}
+	assert.NoError(t, c.loadExportHistory())
+	assert.NoError(t, c.cacheNeosContentServerExport())
+	good, _, _ := c.GetContentServerExportHash()
+	upstream = `{"de":{"id":"root","nodes":{"b":{"id":"b"}}}}`
+	assert.NoError(t, c.cacheNeosContentServerExport())
+
+	second, _, _ := c.GetContentServerExportHash()
+
+	// a broken export has been validated and waits to be published
+	upstream = `{"de":{"id":"root","nodes":{"c":{"id":"c"}}}}`
+	c.publishLock.Lock()
+	refreshed := make(chan error)
+	go func() {
+		refreshed <- c.cacheNeosContentServerExport()
+	}()
+	for report, _ := c.GetReport(); report.Hash == second; report, _ = c.GetReport() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the operator freezes and rolls back meanwhile
+	assert.NoError(t, c.Freeze())
+	c.publishLock.Unlock()
+	assert.Equal(t, ErrorExportFrozen, <-refreshed)
+	assert.NoError(t, c.Rollback(good))
+
+	current, _, _ := c.GetContentServerExportHash()
+	assert.Equal(t, good, current)
+	c.compressions.Wait()
+	files, _ := ioutil.ReadDir(c.versionsDir)
+	assert.Len(t, files, 2*(1+len(utils.Encodings)))
+}
+
+func TestFreeze(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &Cache{file: filepath.Join(dir, "export.json")}
+	frozen, _ := c.Frozen()
+	assert.False(t, frozen)
+
+	assert.NoError(t, c.Freeze())
+	frozen, since := c.Frozen()
+	assert.True(t, frozen)
+	assert.False(t, since.IsZero())
+
+	// survives a restart
+	restarted := &Cache{file: c.file}
+	assert.NoError(t, restarted.loadFrozen())
+	frozen, _ = restarted.Frozen()
+	assert.True(t, frozen)
+
+	assert.NoError(t, restarted.Unfreeze())
+	frozen, _ = restarted.Frozen()
+	assert.False(t, frozen)
+
+	restarted = &Cache{file: c.file}
+	assert.NoError(t, restarted.loadFrozen())
+	frozen, _ = restarted.Frozen()
+	assert.False(t, frozen)
+}
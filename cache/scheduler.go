@@ -8,40 +8,104 @@ import (
 
 func (c *Cache) scheduleInvalidation() {
 
-	skipped := 0
-
 	// logger
 	log := logging.GetDefaultLogEntry().WithField(logging.FieldWorkspace, c.Workspace)
 
 	for {
 
 		select {
-		case requestTime := <-c.invalidationChannel:
-			log.Info("handle invalidation request from queue")
-			time.Sleep(invalidationSleepTime)
+		case <-c.ctx.Done():
+			log.Info("contentserver export invalidation stopped")
+			return
+		case <-c.invalidationChannel:
+		}
 
-			if len(c.invalidationChannel) > 0 && skipped < maxSkipInvalidations {
-				skipped++
-				log.Info("skip invalidation due to more requests in queue")
-				continue
-			}
+		// collect more requests, unless there is no export to serve yet
+		if c.IsReady() && !c.debounceRefresh() {
+			log.Info("contentserver export invalidation stopped")
+			return
+		}
 
-			skipped = 0
-			if errInvalidation := c.cacheNeosContentServerExport(); errInvalidation != nil {
+		// take all pending requests, requests arriving from now on trigger another refresh
+		c.refreshLock.Lock()
+		requests := c.refresh.pending
+		requestTime := c.refresh.firstRequest
+		c.refresh.pending = 0
+		if requests > 0 {
+			c.refresh.skipped += uint64(requests - 1)
+			c.refresh.running = true
+		}
+		c.refreshLock.Unlock()
+		if requests == 0 {
+			continue
+		}
+
+		log.WithField("requests", requests).Info("handle invalidation requests")
+		c.refreshExport(requestTime)
 
-				if errInvalidation == ErrorNoNewExort {
-					log.WithDuration(requestTime).Info("contentserver export cache invalidation request processed - but export hash matches old one")
-					continue
-				}
+		c.refreshLock.Lock()
+		c.refresh.running = false
+		c.refreshLock.Unlock()
+	}
 
-				log.WithError(errInvalidation).Error("cache invalidation failed")
-				continue
-			}
+}
 
-			// @todo: notify observers
+// debounceRefresh waits until no request arrived for the debounce wait or the first pending request waited for the max wait
+// returns false if the cache has been closed meanwhile
+func (c *Cache) debounceRefresh() bool {
+	for {
+		c.refreshLock.Lock()
+		deadline := c.refresh.lastRequest.Add(c.debounce.Wait)
+		if maxDeadline := c.refresh.firstRequest.Add(c.debounce.MaxWait); maxDeadline.Before(deadline) {
+			deadline = maxDeadline
+		}
+		c.refreshLock.Unlock()
 
-			log.WithDuration(requestTime).Info("contentserver export cache invalidation request processed")
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return true
 		}
+		select {
+		case <-c.ctx.Done():
+			return false
+		case <-time.After(wait):
+		}
+	}
+}
+
+// refreshExport downloads the export and logs the outcome
+func (c *Cache) refreshExport(requestTime time.Time) {
+
+	// logger
+	log := logging.GetDefaultLogEntry().WithField(logging.FieldWorkspace, c.Workspace)
+
+	if frozen, since := c.Frozen(); frozen {
+		log.WithField("frozenSince", since).Info("contentserver export invalidation skipped, workspace is frozen")
+		return
+	}
+	if errInvalidation := c.cacheNeosContentServerExport(); errInvalidation != nil {
+
+		if errInvalidation == ErrorExportFrozen {
+			log.WithDuration(requestTime).Info("contentserver export cache invalidation request processed - but workspace has been frozen")
+			return
+		}
+
+		if errInvalidation == ErrorNoNewExort {
+			c.setLoadError(nil)
+			log.WithDuration(requestTime).Info("contentserver export cache invalidation request processed - but export hash matches old one")
+			return
+		}
+
+		if c.ctx.Err() != nil {
+			log.WithError(errInvalidation).Info("contentserver export invalidation cancelled")
+			return
+		}
+
+		c.setLoadError(errInvalidation)
+		log.WithError(errInvalidation).Error("cache invalidation failed")
+		return
 	}
+	c.setLoadError(nil)
 
+	log.WithDuration(requestTime).Info("contentserver export cache invalidation request processed")
 }
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/client/cms"
+	"github.com/foomo/neosproxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// testLoader reports unchanged exports, every download waits for release
+type testLoader struct {
+	downloads int32
+	release   chan struct{}
+}
+
+func (l *testLoader) GetRepository(workspace string, since cms.RepositoryVersion, ctx context.Context) (*cms.Repository, error) {
+	atomic.AddInt32(&l.downloads, 1)
+	<-l.release
+	return nil, cms.ErrorNotModified
+}
+
+func TestDebounceInvalidation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{}
+	cfg.Neos.URL, _ = url.Parse("http://neos.localhost")
+	cfg.Cache.Directory = dir
+	cfg.Cache.ExportHistory = -1
+	cfg.Cache.Debounce = config.Debounce{
+		Wait:       time.Hour,
+		MaxWait:    time.Hour,
+		Workspaces: map[string]config.Debounce{"live": {Wait: 50 * time.Millisecond, MaxWait: 150 * time.Millisecond}},
+	}
+	assert.NoError(t, os.MkdirAll(ExportDirectory(dir), 0755))
+	assert.NoError(t, ioutil.WriteFile(ExportFilename(dir, "live"), []byte(`{}`), 0644))
+
+	loader := &testLoader{release: make(chan struct{})}
+	close(loader.release)
+	c := New(&testBroker{}, loader, "live", cfg)
+	defer c.Close()
+
+	downloads := func() int32 {
+		return atomic.LoadInt32(&loader.downloads)
+	}
+	waitFor := func(condition func() bool) {
+		deadline := time.Now().Add(2 * time.Second)
+		for !condition() && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// a burst of requests => one refresh
+	assert.True(t, c.Invalidate())
+	for i := 0; i < 4; i++ {
+		assert.False(t, c.Invalidate())
+	}
+	status := c.Status()
+	assert.Equal(t, 5, status.PendingRefreshes)
+	assert.False(t, status.PendingSince.IsZero())
+	waitFor(func() bool { return downloads() == 1 && !c.Status().Refreshing })
+	assert.Equal(t, int32(1), downloads())
+	status = c.Status()
+	assert.Equal(t, 0, status.PendingRefreshes)
+	assert.Equal(t, uint64(4), status.SkippedRefreshes)
+
+	// continuous requests are not delayed longer than the max wait
+	stop := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(stop) {
+		c.Invalidate()
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, downloads() >= 3, "downloads %d", downloads())
+	waitFor(func() bool { return c.Status().PendingRefreshes == 0 && !c.Status().Refreshing })
+
+	// a request during a download triggers another refresh afterwards
+	atomic.StoreInt32(&loader.downloads, 0)
+	loader.release = make(chan struct{})
+	c.Invalidate()
+	waitFor(func() bool { return c.Status().Refreshing })
+	assert.True(t, c.Invalidate())
+	assert.Equal(t, 1, c.Status().PendingRefreshes)
+	close(loader.release)
+	waitFor(func() bool { return downloads() == 2 && !c.Status().Refreshing })
+	assert.Equal(t, int32(2), downloads())
+}
@@ -0,0 +1,264 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/klauspost/compress/zstd"
+)
+
+//------------------------------------------------------------------
+// ~ CONSTANTS / VARS
+//------------------------------------------------------------------
+
+const (
+	// Version of the snapshot format
+	Version = 1
+
+	manifestName  = "manifest.json"
+	contentPrefix = "content/"
+	exportPrefix  = "exports/"
+)
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// Manifest describes the contents of a snapshot, it is the last entry of a snapshot
+type Manifest struct {
+	Version    int
+	Created    time.Time
+	Items      int
+	Workspaces []string
+}
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+// Export streams all cached items and the given contentserver export files (workspace => filename)
+// into a zstd compressed tar archive
+func Export(w io.Writer, s store.CacheStore, exports map[string]string) (manifest Manifest, e error) {
+
+	manifest = Manifest{
+		Version:    Version,
+		Created:    time.Now(),
+		Workspaces: []string{},
+	}
+
+	encoder, errEncoder := zstd.NewWriter(w)
+	if errEncoder != nil {
+		e = errEncoder
+		return
+	}
+	tw := tar.NewWriter(encoder)
+
+	// cache items
+	e = store.ForEach(s, func(item store.CacheItem) error {
+		data, errMarshal := json.Marshal(item)
+		if errMarshal != nil {
+			return errMarshal
+		}
+		manifest.Items++
+		return writeEntry(tw, fmt.Sprintf("%s%08d.json", contentPrefix, manifest.Items), data)
+	})
+	if e != nil {
+		return
+	}
+
+	// contentserver exports
+	workspaces := make([]string, 0, len(exports))
+	for workspace := range exports {
+		workspaces = append(workspaces, workspace)
+	}
+	sort.Strings(workspaces)
+	for _, workspace := range workspaces {
+		written, errExport := writeFile(tw, exportPrefix+workspace+".json", exports[workspace])
+		if errExport != nil {
+			e = errExport
+			return
+		}
+		if written {
+			manifest.Workspaces = append(manifest.Workspaces, workspace)
+		}
+	}
+
+	// manifest
+	data, errMarshal := json.Marshal(manifest)
+	if errMarshal != nil {
+		e = errMarshal
+		return
+	}
+	if e = writeEntry(tw, manifestName, data); e != nil {
+		return
+	}
+
+	if e = tw.Close(); e != nil {
+		return
+	}
+	e = encoder.Close()
+	return
+}
+
+// Import reads a snapshot, upserts all items into the given store and writes contentserver exports
+// to the files returned by exportFilename
+func Import(r io.Reader, s store.CacheStore, exportFilename func(workspace string) string) (manifest Manifest, e error) {
+
+	decoder, errDecoder := zstd.NewReader(r)
+	if errDecoder != nil {
+		e = errDecoder
+		return
+	}
+	defer decoder.Close()
+
+	tr := tar.NewReader(decoder)
+	items := 0
+	workspaces := []string{}
+	hasManifest := false
+
+	for {
+		header, errNext := tr.Next()
+		if errNext == io.EOF {
+			break
+		} else if errNext != nil {
+			e = errNext
+			return
+		}
+
+		switch {
+		case header.Name == manifestName:
+			if e = json.NewDecoder(tr).Decode(&manifest); e != nil {
+				return
+			}
+			if manifest.Version != Version {
+				e = fmt.Errorf("snapshot: unsupported version %d", manifest.Version)
+				return
+			}
+			hasManifest = true
+
+		case strings.HasPrefix(header.Name, contentPrefix):
+			item := store.CacheItem{}
+			if e = json.NewDecoder(tr).Decode(&item); e != nil {
+				return
+			}
+			if e = s.Upsert(item); e != nil {
+				return
+			}
+			items++
+
+		case strings.HasPrefix(header.Name, exportPrefix):
+			workspace := strings.TrimSuffix(strings.TrimPrefix(header.Name, exportPrefix), ".json")
+			if workspace == "" || strings.ContainsAny(workspace, `/\`) {
+				e = fmt.Errorf("snapshot: invalid export entry %q", header.Name)
+				return
+			}
+			if e = readFile(tr, exportFilename(workspace)); e != nil {
+				return
+			}
+			workspaces = append(workspaces, workspace)
+		}
+	}
+
+	if !hasManifest {
+		e = fmt.Errorf("snapshot: manifest missing, snapshot incomplete")
+		return
+	}
+	if items != manifest.Items || len(workspaces) != len(manifest.Workspaces) {
+		e = fmt.Errorf("snapshot: manifest mismatch, expected %d items and %d exports, got %d and %d", manifest.Items, len(manifest.Workspaces), items, len(workspaces))
+		return
+	}
+	return
+}
+
+// Migrate copies all cached items from one store into another
+func Migrate(from store.CacheStore, to store.CacheStore) (count int, e error) {
+	e = store.ForEach(from, func(item store.CacheItem) error {
+		if err := to.Upsert(item); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	errHeader := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if errHeader != nil {
+		return errHeader
+	}
+	_, errWrite := tw.Write(data)
+	return errWrite
+}
+
+// writeFile adds a file to the archive, missing files will be skipped
+func writeFile(tw *tar.Writer, name string, filename string) (bool, error) {
+	file, errOpen := os.Open(filename)
+	if os.IsNotExist(errOpen) {
+		return false, nil
+	} else if errOpen != nil {
+		return false, errOpen
+	}
+	defer file.Close()
+
+	fileInfo, errStat := file.Stat()
+	if errStat != nil {
+		return false, errStat
+	}
+
+	errHeader := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    fileInfo.Size(),
+		ModTime: fileInfo.ModTime(),
+	})
+	if errHeader != nil {
+		return false, errHeader
+	}
+
+	_, errCopy := io.Copy(tw, file)
+	return errCopy == nil, errCopy
+}
+
+// readFile writes an archive entry to a file, the file will be replaced atomically
+func readFile(r io.Reader, filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	file, errTemp := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".import")
+	if errTemp != nil {
+		return errTemp
+	}
+	defer os.Remove(file.Name())
+
+	if _, errCopy := io.Copy(file, r); errCopy != nil {
+		file.Close()
+		return errCopy
+	}
+	if errClose := file.Close(); errClose != nil {
+		return errClose
+	}
+	if errChmod := os.Chmod(file.Name(), 0644); errChmod != nil {
+		return errChmod
+	}
+	return os.Rename(file.Name(), filename)
+}
@@ -0,0 +1,65 @@
+package snapshot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/content/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	exportFile := filepath.Join(dir, "live.json")
+	assert.NoError(t, ioutil.WriteFile(exportFile, []byte(`{"de":{}}`), 0644))
+
+	source := memory.NewCacheStore()
+	assert.NoError(t, source.Upsert(store.NewCacheItem("1", "de", "live", "<h1>1</h1>", []string{"2"}, store.ValidUntilForever)))
+	assert.NoError(t, source.Upsert(store.NewCacheItem("2", "de", "live", "<h1>2</h1>", nil, store.ValidUntilForever)))
+
+	buf := &bytes.Buffer{}
+	manifest, errExport := Export(buf, source, map[string]string{
+		"live":  exportFile,
+		"stage": filepath.Join(dir, "missing.json"),
+	})
+	assert.NoError(t, errExport)
+	assert.Equal(t, 2, manifest.Items)
+	assert.Equal(t, []string{"live"}, manifest.Workspaces)
+
+	target := memory.NewCacheStore()
+	imported, errImport := Import(buf, target, func(workspace string) string {
+		return filepath.Join(dir, "imported", workspace+".json")
+	})
+	assert.NoError(t, errImport)
+	assert.Equal(t, manifest.Items, imported.Items)
+
+	item, errGet := target.Get(store.GetHash("1", "de", "live"))
+	assert.NoError(t, errGet)
+	assert.Equal(t, "<h1>1</h1>", item.HTML)
+	assert.Equal(t, []string{"2"}, item.Dependencies)
+
+	data, errRead := ioutil.ReadFile(filepath.Join(dir, "imported", "live.json"))
+	assert.NoError(t, errRead)
+	assert.Equal(t, `{"de":{}}`, string(data))
+}
+
+func TestMigrate(t *testing.T) {
+	source := memory.NewCacheStore()
+	assert.NoError(t, source.Upsert(store.NewCacheItem("1", "de", "live", "<h1>1</h1>", nil, store.ValidUntilForever)))
+
+	target := memory.NewCacheStore()
+	count, errMigrate := Migrate(source, target)
+	assert.NoError(t, errMigrate)
+	assert.Equal(t, 1, count)
+
+	targetCount, errCount := target.Count()
+	assert.NoError(t, errCount)
+	assert.Equal(t, 1, targetCount)
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/foomo/neosproxy/logging"
+)
+
+// Preload loads the contentserver export in the background, retrying in the given interval until a valid export exists
+// an existing export is refreshed once
+func (c *Cache) Preload(retryInterval time.Duration) {
+
+	// logger
+	log := logging.GetDefaultLogEntry().WithField(logging.FieldWorkspace, c.Workspace)
+
+	go func() {
+		c.Invalidate()
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-c.ready:
+				return
+			case <-time.After(retryInterval):
+				log.WithField("lastError", c.loadStatus().err).Warn("no valid contentserver export yet, retrying")
+				c.Invalidate()
+			}
+		}
+	}()
+}
+
+// Ready is closed as soon as a valid contentserver export exists
+func (c *Cache) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// IsReady is true if a valid contentserver export exists
+func (c *Cache) IsReady() bool {
+	select {
+	case <-c.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Cache) markReady() {
+	c.readyOnce.Do(func() {
+		close(c.ready)
+	})
+}
+
+// setLoadError remembers the error of the last export load, nil clears it
+func (c *Cache) setLoadError(err error) {
+	c.loadLock.Lock()
+	defer c.loadLock.Unlock()
+	if err == nil {
+		c.load = loadStatus{}
+		return
+	}
+	c.load = loadStatus{
+		err: err.Error(),
+		at:  time.Now(),
+	}
+}
+
+func (c *Cache) loadStatus() loadStatus {
+	c.loadLock.RLock()
+	defer c.loadLock.RUnlock()
+	return c.load
+}
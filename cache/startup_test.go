@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/client/cms"
+	"github.com/foomo/neosproxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the first load fails, NEOS is still starting
+	requests := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"de":{"id":"root","nodes":{"a":{"id":"a"}}}}`))
+	}))
+	defer server.Close()
+	client, errClient := cms.New(server.URL)
+	assert.NoError(t, errClient)
+	neosURL, _ := url.Parse(server.URL)
+
+	cfg := &config.Config{
+		Neos:  config.Neos{URL: neosURL},
+		Cache: config.Cache{Directory: dir, ExportHistory: -1},
+	}
+	c := New(&testBroker{}, client.CMS, "live", cfg)
+	defer c.Close()
+	assert.False(t, c.IsReady())
+	assert.False(t, c.Status().Ready)
+
+	c.Preload(50 * time.Millisecond)
+	select {
+	case <-c.Ready():
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "contentserver export not loaded")
+		return
+	}
+	assert.True(t, c.IsReady())
+	assert.True(t, atomic.LoadInt32(&requests) >= 2)
+
+	hash, _, errHash := c.GetContentServerExportHash()
+	assert.NoError(t, errHash)
+	assert.Equal(t, hash, c.Status().Hash)
+
+	// an existing export is ready right away
+	restarted := New(&testBroker{}, nil, "live", cfg)
+	defer restarted.Close()
+	assert.True(t, restarted.IsReady())
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/foomo/neosproxy/cache/export"
+	"github.com/foomo/neosproxy/model"
+)
+
+// GetReport returns the validation report of the last downloaded export
+func (c *Cache) GetReport() (report model.Report, ok bool) {
+	c.reportLock.RLock()
+	defer c.reportLock.RUnlock()
+	if c.report == nil {
+		return
+	}
+	return *c.report, true
+}
+
+// validateExport validates a downloaded export with its index and records a report
+// errRead is the error of indexing the export, the node count of the current export is the base of the shrink rule
+func (c *Cache) validateExport(filename string, index *export.Index, errRead error, url string, hash string) (report model.Report, valid bool) {
+	report = model.Report{
+		Name:      "contentserver export",
+		URL:       url,
+		Status:    model.ReportStatusValid,
+		DateTime:  time.Now(),
+		Hash:      hash,
+		Workspace: c.Workspace,
+		Messages:  []model.Message{},
+	}
+
+	if c.validation != nil {
+		report.Messages = c.validation.ValidateSchema(filename)
+	}
+	if export.Valid(report.Messages) {
+		switch {
+		case errRead != nil:
+			report.Messages = append(report.Messages, export.ReadErrorMessage(errRead))
+		case c.validation != nil:
+			report.Messages = append(report.Messages, c.validation.ValidateIndex(index, c.previousNodeCount())...)
+		}
+	}
+	if !export.Valid(report.Messages) {
+		report.Status = model.ReportStatusInvalid
+	}
+
+	c.reportLock.Lock()
+	c.report = &report
+	c.reportLock.Unlock()
+
+	return report, report.Status == model.ReportStatusValid
+}
+
+// previousNodeCount returns the number of nodes of the current export, 0 if unknown
+func (c *Cache) previousNodeCount() int {
+	if c.validation.MaxShrink <= 0 {
+		return 0
+	}
+	index, _, errIndex := c.currentIndex()
+	if errIndex != nil {
+		return 0
+	}
+	return index.Count()
+}
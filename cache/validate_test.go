@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/foomo/neosproxy/cache/export"
+	"github.com/foomo/neosproxy/client/cms"
+	"github.com/foomo/neosproxy/config"
+	"github.com/foomo/neosproxy/model"
+	"github.com/foomo/neosproxy/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	upstream := `{"de":{"id":"root","nodes":{"a":{"id":"a"},"b":{"id":"b"},"c":{"id":"c"}}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(upstream))
+	}))
+	defer server.Close()
+	client, errClient := cms.New(server.URL)
+	assert.NoError(t, errClient)
+
+	rules, errRules := export.NewRules(config.ExportValidation{MinNodes: 2, MaxShrink: 40}, []string{"de"})
+	assert.NoError(t, errRules)
+
+	broker := &testBroker{}
+	c := &Cache{
+		Workspace:   "live",
+		file:        filepath.Join(dir, "export.json"),
+		versionsDir: filepath.Join(dir, "versions"),
+		historyDir:  filepath.Join(dir, "history"),
+		historySize: -1,
+		broker:      broker,
+		neos:        config.Neos{URL: client.Endpoint},
+		ctx:         context.Background(),
+		loader:      client.CMS,
+		validation:  rules,
+		ready:       make(chan struct{}),
+	}
+
+	assert.NoError(t, c.cacheNeosContentServerExport())
+	report, ok := c.GetReport()
+	assert.True(t, ok)
+	assert.Equal(t, model.ReportStatusValid, report.Status)
+	accepted, _, errHash := c.GetContentServerExportHash()
+	assert.NoError(t, errHash)
+
+	// 4 => 2 nodes
+	upstream = `{"de":{"id":"root","nodes":{"a":{"id":"a"}}}}`
+	assert.Equal(t, ErrorInvalidExport, c.cacheNeosContentServerExport())
+	report, _ = c.GetReport()
+	assert.Equal(t, model.ReportStatusInvalid, report.Status)
+	assert.Len(t, broker.rejections, 1)
+	assert.Equal(t, 1, broker.notifications)
+
+	// the previous export is still served
+	hash, _, errHash := c.GetContentServerExportHash()
+	assert.NoError(t, errHash)
+	assert.Equal(t, accepted, hash)
+	_, errStat := os.Stat(c.file + ".download")
+	assert.True(t, os.IsNotExist(errStat))
+
+	// missing dimension
+	upstream = `{"fr":{"id":"root","nodes":{"a":{"id":"a"},"b":{"id":"b"},"c":{"id":"c"}}}}`
+	assert.Equal(t, ErrorInvalidExport, c.cacheNeosContentServerExport())
+	assert.Len(t, broker.rejections, 2)
+	assert.Equal(t, "missing dimension", broker.rejections[1].Messages[0].Message)
+
+	// not readable
+	upstream = `{"de":{"id":"root","nodes":{"a":{"id":"a"},"b":{"id":"b"},"c":{"id":"c"}}},"en":[]}`
+	assert.Equal(t, ErrorInvalidExport, c.cacheNeosContentServerExport())
+	assert.Len(t, broker.rejections, 3)
+
+	// rejected exports leave no dimension slices behind: the current export and its slice
+	c.compressions.Wait()
+	files, _ := ioutil.ReadDir(c.versionsDir)
+	assert.Len(t, files, 2*(1+len(utils.Encodings)))
+}
@@ -0,0 +1,383 @@
+package cache
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/export"
+	"github.com/foomo/neosproxy/logging"
+	"github.com/sirupsen/logrus"
+)
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// exportVersion is an immutable contentserver export file with its compressed variants
+// the current version and every reader hold a reference, the files are removed with the last release
+type exportVersion struct {
+	hash       string
+	filename   string
+	compressed map[string]string // encoding => filename
+	fileInfo   os.FileInfo
+	refs       int
+	dimensions map[string]*exportVersion // slices of single dimensions, sharing the references of the version
+	index      *export.Index             // nil, if the export could not be indexed
+}
+
+// Export is a version of the contentserver export, it stays readable until it is released, even if it has been replaced meanwhile
+type Export struct {
+	Hash    string
+	ModTime time.Time
+	Size    int64
+
+	cache   *Cache
+	version *exportVersion // served files
+	release *exportVersion // referenced version
+	once    sync.Once
+}
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+// ExportVersionsDirectory returns the directory of the served contentserver export versions of a workspace
+func ExportVersionsDirectory(cacheDirectory string, workspace string) string {
+	return filepath.Join(ExportDirectory(cacheDirectory), "versions", workspace)
+}
+
+// AcquireExport returns the current contentserver export, release it after reading
+// ErrorFileNotExists will be returned if there is no export yet
+func (c *Cache) AcquireExport() (*Export, error) {
+	return c.AcquireDimensionExport("")
+}
+
+// AcquireDimensionExport returns the slice of the current contentserver export with a single dimension, release it after reading
+// an empty dimension returns the whole export
+// ErrorFileNotExists will be returned if there is no export yet, ErrorDimensionNotExists if the export does not contain the dimension
+func (c *Cache) AcquireDimensionExport(dimension string) (*Export, error) {
+	c.versionLock.Lock()
+	defer c.versionLock.Unlock()
+
+	if c.current == nil {
+		return nil, ErrorFileNotExists
+	}
+	version := c.current
+	if dimension != "" {
+		var ok bool
+		if version, ok = c.current.dimensions[dimension]; !ok {
+			return nil, ErrorDimensionNotExists
+		}
+	}
+	c.current.refs++
+	return &Export{
+		Hash:    version.hash,
+		ModTime: version.fileInfo.ModTime(),
+		Size:    version.fileInfo.Size(),
+		cache:   c,
+		version: version,
+		release: c.current,
+	}, nil
+}
+
+// Open the export file, close it before releasing the export
+func (e *Export) Open() (*os.File, error) {
+	return os.Open(e.version.filename)
+}
+
+// OpenCompressed opens a pre-compressed variant of the export, close it before releasing the export
+// ErrorFileNotExists will be returned if there is no variant for the encoding (yet)
+func (e *Export) OpenCompressed(encoding string) (*os.File, error) {
+	e.cache.versionLock.Lock()
+	filename, ok := e.version.compressed[encoding]
+	e.cache.versionLock.Unlock()
+	if !ok {
+		return nil, ErrorFileNotExists
+	}
+	return os.Open(filename)
+}
+
+// Release the export, calling it more than once is safe
+func (e *Export) Release() {
+	e.once.Do(func() {
+		e.cache.releaseVersion(e.release)
+	})
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+// newVersion of an export with the given hash, its file is moved into place by publishVersion
+func (c *Cache) newVersion(hash string) (*exportVersion, error) {
+	if err := os.MkdirAll(c.versionsDir, 0755); err != nil {
+		return nil, err
+	}
+	return &exportVersion{
+		hash:       hash,
+		filename:   filepath.Join(c.versionsDir, fmt.Sprintf("%s-%d.json", hash, time.Now().UnixNano())),
+		compressed: map[string]string{},
+		refs:       1, // held by the current pointer
+		dimensions: map[string]*exportVersion{},
+	}, nil
+}
+
+// publishExport publishes a downloaded export, unless the export has been frozen meanwhile
+// the frozen state is checked under publishLock, a running refresh never replaces a rolled back export
+func (c *Cache) publishExport(version *exportVersion, filename string, index *export.Index) (fileInfo os.FileInfo, err error) {
+
+	c.publishLock.Lock()
+	defer c.publishLock.Unlock()
+
+	if frozen, _ := c.Frozen(); frozen {
+		version.remove()
+		return nil, ErrorExportFrozen
+	}
+	return c.publishVersion(version, filename, index)
+}
+
+// publishVersion moves an export file into its version and makes it the current one, lock publishLock
+// a version without index, because its export could not be read, serves no node lookups
+// compressed variants are written in the background, until then responses are compressed on the fly
+// readers of the previous version are not blocked, its files are removed once they released it
+func (c *Cache) publishVersion(version *exportVersion, filename string, index *export.Index) (fileInfo os.FileInfo, err error) {
+	if err = os.Rename(filename, version.filename); err != nil {
+		version.remove()
+		return
+	}
+	version.fileInfo, err = os.Stat(version.filename)
+
+	// the current export stays available at its well known location for restarts and snapshots
+	if err == nil {
+		err = linkFile(version.filename, c.file)
+	}
+	if err != nil {
+		version.remove()
+		return
+	}
+	version.index = index
+
+	c.versionLock.Lock()
+	previous := c.current
+	c.current = version
+	version.refs++ // held by the compression
+	c.versionLock.Unlock()
+	if previous != nil {
+		c.releaseVersion(previous)
+	}
+
+	c.compressions.Add(1)
+	go c.compressVersion(version)
+
+	fileInfo = version.fileInfo
+	return
+}
+
+// publishExportFile reads and publishes an export file, which has been validated before, lock publishLock
+// an export that can not be read is published without dimension slices and index
+func (c *Cache) publishExportFile(filename string, hash string) (fileInfo os.FileInfo, err error) {
+	version, err := c.newVersion(hash)
+	if err != nil {
+		return
+	}
+	index, errRead := version.read(filename)
+	if errRead != nil {
+		logging.GetDefaultLogEntry().WithError(errRead).WithFields(logrus.Fields{
+			logging.FieldWorkspace: c.Workspace,
+			"hash":                 hash,
+		}).Error("failed reading contentserver export, dimension slices and node lookups are not available")
+	}
+	return c.publishVersion(version, filename, index)
+}
+
+// releaseVersion drops a reference and removes the files of an unused version
+func (c *Cache) releaseVersion(version *exportVersion) {
+	c.versionLock.Lock()
+	version.refs--
+	unused := version.refs == 0
+	c.versionLock.Unlock()
+
+	if unused {
+		version.remove()
+		logging.GetDefaultLogEntry().WithFields(logrus.Fields{
+			logging.FieldWorkspace: c.Workspace,
+			"hash":                 version.hash,
+		}).Debug("removed replaced contentserver export version")
+	}
+}
+
+// compressVersion writes the compressed variants of a version and its dimension slices
+// a version replaced meanwhile is not compressed any further
+func (c *Cache) compressVersion(version *exportVersion) {
+
+	start := time.Now()
+
+	defer c.compressions.Done()
+	defer c.releaseVersion(version)
+
+	// logger
+	log := logging.GetDefaultLogEntry().WithFields(logrus.Fields{
+		logging.FieldWorkspace: c.Workspace,
+		"hash":                 version.hash,
+	})
+
+	versions := []*exportVersion{version}
+	for _, dimension := range version.dimensions {
+		versions = append(versions, dimension)
+	}
+	for _, v := range versions {
+		c.versionLock.Lock()
+		replaced := c.current != version
+		c.versionLock.Unlock()
+		if replaced {
+			log.Debug("stopped compressing replaced contentserver export version")
+			return
+		}
+
+		compressed, errCompress := compressFile(v.filename)
+		if errCompress != nil {
+			log.WithError(errCompress).Warn("failed compressing contentserver export, responses are compressed on the fly")
+			return
+		}
+		c.versionLock.Lock()
+		v.compressed = compressed
+		c.versionLock.Unlock()
+	}
+	log.WithDuration(start).Debug("compressed contentserver export")
+}
+
+// hasExport is true if there is a current export
+func (c *Cache) hasExport() bool {
+	c.versionLock.Lock()
+	defer c.versionLock.Unlock()
+	return c.current != nil
+}
+
+// loadCurrentExport publishes the export at its well known location, versions of a previous run are removed
+func (c *Cache) loadCurrentExport() error {
+
+	c.publishLock.Lock()
+	defer c.publishLock.Unlock()
+
+	// nobody reads versions of a previous run anymore
+	if err := os.RemoveAll(c.versionsDir); err != nil {
+		return err
+	}
+
+	// compressed variants next to the export of older releases
+	for _, extension := range compressedExtensions {
+		os.Remove(c.file + extension)
+	}
+
+	if _, errStat := os.Stat(c.file); errStat != nil {
+		if os.IsNotExist(errStat) {
+			return nil
+		}
+		return errStat
+	}
+
+	hash, errHash := hashFile(c.file)
+	if errHash != nil {
+		return errHash
+	}
+
+	// linked, the export keeps its modification time
+	filename := c.file + ".load"
+	if errLink := linkFile(c.file, filename); errLink != nil {
+		return errLink
+	}
+	if _, errPublish := c.publishExportFile(filename, hash); errPublish != nil {
+		os.Remove(filename)
+		return errPublish
+	}
+	return nil
+}
+
+// read indexes an export file in a single pass, the index is shared by validation and node lookups
+// the slice of every dimension is copied next to the version file meanwhile, one dimension at a time
+func (v *exportVersion) read(filename string) (*export.Index, error) {
+	file, errOpen := os.Open(filename)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+	defer file.Close()
+
+	index, errScan := export.Scan(file, v.addDimension)
+	if errScan != nil {
+		for _, dimension := range v.dimensions {
+			dimension.remove()
+		}
+		v.dimensions = map[string]*exportVersion{}
+		return nil, errScan
+	}
+	return index, nil
+}
+
+// addDimension writes the slice of a single dimension next to the version file
+func (v *exportVersion) addDimension(dimension string, root io.Reader) (err error) {
+	key, errMarshal := json.Marshal(dimension)
+	if errMarshal != nil {
+		return errMarshal
+	}
+	dimensionVersion := &exportVersion{
+		filename:   strings.TrimSuffix(v.filename, ".json") + "." + url.PathEscape(dimension) + ".json",
+		compressed: map[string]string{},
+	}
+	v.dimensions[dimension] = dimensionVersion
+
+	file, errCreate := os.Create(dimensionVersion.filename)
+	if errCreate != nil {
+		return errCreate
+	}
+	defer func() {
+		if errClose := file.Close(); err == nil {
+			err = errClose
+		}
+	}()
+
+	hash := md5.New()
+	w := io.MultiWriter(file, hash)
+	slice := io.MultiReader(strings.NewReader("{"+string(key)+":"), root, strings.NewReader("}"))
+	if _, err = io.Copy(w, slice); err != nil {
+		return
+	}
+	dimensionVersion.hash = hex.EncodeToString(hash.Sum(nil))
+	dimensionVersion.fileInfo, err = file.Stat()
+	return
+}
+
+func (v *exportVersion) remove() {
+	os.Remove(v.filename)
+	for _, filename := range v.compressed {
+		os.Remove(filename)
+	}
+	for _, dimension := range v.dimensions {
+		dimension.remove()
+	}
+}
+
+// linkFile atomically replaces target by a hard link of filename, or by a copy if hard links are not supported
+func linkFile(filename string, target string) error {
+	tmp := target + ".link"
+	os.Remove(tmp)
+	if errLink := os.Link(filename, tmp); errLink != nil {
+		if errCopy := copyFile(filename, tmp); errCopy != nil {
+			os.Remove(tmp)
+			return errCopy
+		}
+	}
+	errRename := os.Rename(tmp, target)
+
+	// renaming a link onto the same file succeeds without removing it
+	os.Remove(tmp)
+	return errRename
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/foomo/neosproxy/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &Cache{
+		file:        filepath.Join(dir, "export.json"),
+		versionsDir: filepath.Join(dir, "versions"),
+	}
+
+	// no export yet
+	_, errExport := c.AcquireExport()
+	assert.Equal(t, ErrorFileNotExists, errExport)
+
+	publish := func(data string) string {
+		assert.NoError(t, ioutil.WriteFile(c.file+".download", []byte(data), 0644))
+		hash, errHash := hashFile(c.file + ".download")
+		assert.NoError(t, errHash)
+		_, errPublish := c.publishExportFile(c.file+".download", hash)
+		assert.NoError(t, errPublish)
+		c.compressions.Wait()
+		return hash
+	}
+	read := func(export *Export) string {
+		file, errOpen := export.Open()
+		assert.NoError(t, errOpen)
+		defer file.Close()
+		data, errRead := ioutil.ReadAll(file)
+		assert.NoError(t, errRead)
+		return string(data)
+	}
+
+	first := publish(`{"de":{"id":"bar"},"en":{"id":"bar"}}`)
+	export, errExport := c.AcquireExport()
+	assert.NoError(t, errExport)
+	assert.Equal(t, first, export.Hash)
+	assert.Equal(t, int64(37), export.Size)
+
+	// compressed variants
+	for _, encoding := range utils.Encodings {
+		file, errFile := export.OpenCompressed(encoding)
+		assert.NoError(t, errFile)
+		file.Close()
+	}
+	_, errFile := export.OpenCompressed("deflate")
+	assert.Equal(t, ErrorFileNotExists, errFile)
+
+	// dimension slices
+	de, errDimension := c.AcquireDimensionExport("de")
+	assert.NoError(t, errDimension)
+	sum := md5.Sum([]byte(`{"de":{"id":"bar"}}`))
+	assert.Equal(t, hex.EncodeToString(sum[:]), de.Hash)
+	assert.Equal(t, `{"de":{"id":"bar"}}`, read(de))
+	compressed, errCompressed := de.OpenCompressed(utils.EncodingGzip)
+	assert.NoError(t, errCompressed)
+	compressed.Close()
+	_, errDimension = c.AcquireDimensionExport("fr")
+	assert.Equal(t, ErrorDimensionNotExists, errDimension)
+
+	// a reader keeps its version while a new one is published
+	second := publish(`{"de":{"id":"baz"}}`)
+	assert.Equal(t, `{"de":{"id":"bar"},"en":{"id":"bar"}}`, read(export))
+	assert.Equal(t, `{"de":{"id":"bar"}}`, read(de))
+	hash, _, errHash := c.GetContentServerExportHash()
+	assert.NoError(t, errHash)
+	assert.Equal(t, second, hash)
+	data, errRead := ioutil.ReadFile(c.file)
+	assert.NoError(t, errRead)
+	assert.Equal(t, `{"de":{"id":"baz"}}`, string(data))
+
+	// export and dimension slices with their compressed variants
+	files, _ := ioutil.ReadDir(c.versionsDir)
+	assert.Len(t, files, (3+2)*(1+len(utils.Encodings)))
+
+	// the replaced version is removed with the last release
+	export.Release()
+	export.Release()
+	files, _ = ioutil.ReadDir(c.versionsDir)
+	assert.Len(t, files, (3+2)*(1+len(utils.Encodings)))
+	de.Release()
+	files, _ = ioutil.ReadDir(c.versionsDir)
+	assert.Len(t, files, 2*(1+len(utils.Encodings)))
+
+	current, errExport := c.AcquireExport()
+	assert.NoError(t, errExport)
+	assert.Equal(t, `{"de":{"id":"baz"}}`, read(current))
+	current.Release()
+
+	// the current export is loaded on restart, versions of the previous run are removed
+	restarted := &Cache{
+		file:        c.file,
+		versionsDir: c.versionsDir,
+	}
+	assert.NoError(t, restarted.loadCurrentExport())
+	restarted.compressions.Wait()
+	hash, fileInfo, errHash := restarted.GetContentServerExportHash()
+	assert.NoError(t, errHash)
+	assert.Equal(t, second, hash)
+	exportInfo, _ := os.Stat(c.file)
+	assert.Equal(t, exportInfo.ModTime(), fileInfo.ModTime())
+	files, _ = ioutil.ReadDir(c.versionsDir)
+	assert.Len(t, files, 2*(1+len(utils.Encodings)))
+
+	// exports which can not be read are served without dimension slices and index
+	broken := publish(`{"de":[]}`)
+	hash, _, _ = c.GetContentServerExportHash()
+	assert.Equal(t, broken, hash)
+	_, errDimension = c.AcquireDimensionExport("de")
+	assert.Equal(t, ErrorDimensionNotExists, errDimension)
+	_, _, errNode := c.GetNode("de", "baz")
+	assert.Equal(t, ErrorIndexNotAvailable, errNode)
+}
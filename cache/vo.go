@@ -1,27 +1,99 @@
 package cache
 
 import (
+	"context"
 	"sync"
 	"time"
 
+	"github.com/foomo/neosproxy/cache/export"
+	"github.com/foomo/neosproxy/client/cms"
 	"github.com/foomo/neosproxy/config"
+	"github.com/foomo/neosproxy/model"
 )
 
 // Cache workspace items
 type Cache struct {
 	Workspace           string
-	invalidationChannel chan time.Time
+	invalidationChannel chan time.Time // wakes up the refresh loop
+	debounce            config.Debounce
+	refreshLock         sync.Mutex
+	refresh             refreshState
 
-	file     string
-	FileLock sync.RWMutex
+	file         string // the current export at its well known location
+	versionsDir  string
+	publishLock  sync.Mutex
+	versionLock  sync.Mutex     // short, never held while reading or writing files
+	current      *exportVersion // served export
+	compressions sync.WaitGroup // of published versions, running in the background
+
+	historyLock sync.RWMutex
+	historyDir  string
+	historySize int                     // exports retained, defaulted by the config, < 0 === disabled
+	history     []ExportVersion         // retained exports, oldest first
+	deltas      map[string]*ExportDelta // from + to hash => delta
+
+	frozenLock sync.RWMutex
+	frozenAt   time.Time // automatic refreshes are stopped, if set
+
+	readyOnce sync.Once
+	ready     chan struct{} // closed once a valid export exists
+	loadLock  sync.RWMutex
+	load      loadStatus // of the last export load
+
+	validation *export.Rules
+	reportLock sync.RWMutex
+	report     *model.Report // validation report of the last downloaded export
 
 	config config.Cache
 	neos   config.Neos
 
+	ctx          context.Context // cancelled on Close
+	cancel       context.CancelFunc
+	loader       cms.RepositoryLoader
+	upstreamLock sync.Mutex
+	upstream     upstreamVersion // validators NEOS sent for the latest downloaded export
+
 	broker Broker
 }
 
+// ExportVersion of a retained contentserver export
+type ExportVersion struct {
+	Hash    string    `json:"hash"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+}
+
+// ExportDelta lists all nodes added, removed or changed between two exports per dimension
+type ExportDelta struct {
+	Workspace  string                           `json:"workspace"`
+	From       string                           `json:"from"`
+	To         string                           `json:"to"`
+	Dimensions map[string]export.DimensionDelta `json:"dimensions"`
+}
+
+// refreshState of requested contentserver export refreshes
+type refreshState struct {
+	pending      int // requests waiting for the next refresh
+	firstRequest time.Time
+	lastRequest  time.Time
+	running      bool
+	skipped      uint64 // requests served by the refresh of another request
+}
+
+// upstreamVersion are the validators NEOS sent for an export
+type upstreamVersion struct {
+	Hash string `json:"hash"` // of the export
+	cms.RepositoryVersion
+}
+
+// loadStatus of an export load, empty if it succeeded
+type loadStatus struct {
+	err string
+	at  time.Time
+}
+
 // Broker to handle content structure changes
 type Broker interface {
 	NotifyOnSitemapChange(workspace string)
+	NotifyOnExportRejected(workspace string, report model.Report)
 }
@@ -19,6 +19,12 @@ import (
 
 const defaultContentType = "application/json"
 
+// retries of requests failing with network errors or temporarily unavailable upstreams
+const (
+	defaultRetries      = 2
+	defaultRetryBackoff = 500 * time.Millisecond
+)
+
 //------------------------------------------------------------------
 // ~ CONSTRUCTOR
 //------------------------------------------------------------------
@@ -42,8 +48,15 @@ func New(endpoint string) (*Client, error) {
 	}
 
 	c := &Client{
-		client:   httpClient,
+		client: httpClient,
+		repositoryClient: &http.Client{
+			Timeout:   repositoryTimeout,
+			Transport: transport,
+		},
 		Endpoint: endpointURL,
+
+		retries:      defaultRetries,
+		retryBackoff: defaultRetryBackoff,
 	}
 
 	c.CMS = NewCMSService(c)
@@ -78,7 +91,7 @@ func (c *Client) NewGetRequest(path string, body interface{}) (*http.Request, er
 
 //Do will send an API request and returns the API response (decodes and serializes)
 func (c *Client) Do(req *http.Request, ctx context.Context, v interface{}) *ClientError {
-	resp, err := c.client.Do(req.WithContext(ctx))
+	resp, err := c.doWithRetry(c.client, req, ctx)
 	if err != nil {
 		return CreateClientError(errors.Wrap(err, "could not execute request"), req, resp, nil)
 	}
@@ -107,6 +120,38 @@ func (c *Client) Do(req *http.Request, ctx context.Context, v interface{}) *Clie
 // ~ PRIVATE METHODS
 //------------------------------------------------------------------
 
+// doWithRetry sends a request without body, network errors and 502, 503, 504 responses are retried with an exponential backoff
+func (c *Client) doWithRetry(client *http.Client, req *http.Request, ctx context.Context) (resp *http.Response, err error) {
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err = client.Do(req.WithContext(ctx))
+		if attempt >= c.retries || ctx.Err() != nil || !isRetryable(resp, err) {
+			return
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func isRetryable(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch response.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
 func isHTPPResponse2xx(response *http.Response) bool {
 	return response.StatusCode >= 200 && response.StatusCode < 300
 }
@@ -9,6 +9,7 @@ import "context"
 // Service to load
 type Service interface {
 	GetContent(id string, dimension string, workspace string, ctx context.Context) (content Content, e error)
+	GetRepository(workspace string, since RepositoryVersion, ctx context.Context) (repository *Repository, e error)
 
 	// GetRepo(id string, dimension string) (html string, e error)
 	// GetImage(id string, dimension string) (html string, e error)
@@ -19,3 +20,8 @@ type Service interface {
 type ContentLoader interface {
 	GetContent(id, dimension, workspace string, ctx context.Context) (content Content, e error)
 }
+
+// RepositoryLoader interface
+type RepositoryLoader interface {
+	GetRepository(workspace string, since RepositoryVersion, ctx context.Context) (repository *Repository, e error)
+}
@@ -0,0 +1,110 @@
+package cms
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/foomo/neosproxy/logging"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+//-----------------------------------------------------------------------------
+// ~ CONSTANTS / VARS
+//-----------------------------------------------------------------------------
+
+// repositoryTimeout limits a whole export download, including reading the body
+const repositoryTimeout = 10 * time.Minute
+
+// maxErrorBodySize limits the body read of failed requests
+const maxErrorBodySize = 4096
+
+// ErrorNotModified in case the repository did not change since the given version
+var ErrorNotModified = errors.New("not modified")
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// RepositoryVersion identifies a contentserver export by its response validators
+type RepositoryVersion struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"lastModified,omitempty"`
+}
+
+// Repository is a streamed contentserver export, the body has to be closed
+type Repository struct {
+	Body    io.ReadCloser
+	Version RepositoryVersion
+}
+
+//-----------------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//-----------------------------------------------------------------------------
+
+// GetRepository streams the contentserver export of a workspace
+// ErrorNotModified will be returned if the export did not change since the given version
+func (s *cmsService) GetRepository(workspace string, since RepositoryVersion, ctx context.Context) (repository *Repository, e error) {
+
+	l := s.logger.WithFields(logrus.Fields{
+		logging.FieldFunction:  "GetRepository",
+		logging.FieldWorkspace: workspace,
+	})
+
+	req, reqErr := s.client.NewGetRequest(pathRepository+"?workspace="+url.QueryEscape(workspace), nil)
+	if reqErr != nil {
+		l.WithError(reqErr).Error("unable to create cms get repository request")
+		e = ErrorRequest
+		return
+	}
+	req.Header.Del("Content-Type")
+	if since.ETag != "" {
+		etag := since.ETag
+		if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, "W/") {
+			etag = `"` + etag + `"`
+		}
+		req.Header.Set("If-None-Match", etag)
+	}
+	if !since.LastModified.IsZero() {
+		req.Header.Set("If-Modified-Since", since.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	resp, respErr := s.client.doWithRetry(s.client.repositoryClient, req, ctx)
+	if respErr != nil {
+		var clientErr error
+		e, clientErr = s.convertClientErr(CreateClientError(errors.Wrap(respErr, "could not execute request"), req, nil, nil), ctx)
+		l.WithError(clientErr).Error("unable to load repository from cms")
+		return
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		e = ErrorNotModified
+		return
+	}
+
+	if !isHTPPResponse2xx(resp) {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		resp.Body.Close()
+		var clientErr error
+		e, clientErr = s.convertClientErr(CreateClientError(errors.New("cms client error"), req, resp, data), ctx)
+		l.WithError(clientErr).WithField("status", resp.StatusCode).Error("unable to load repository from cms")
+		return
+	}
+
+	repository = &Repository{
+		Body: resp.Body,
+		Version: RepositoryVersion{
+			ETag: resp.Header.Get("ETag"),
+		},
+	}
+	if lastModified, errParse := http.ParseTime(resp.Header.Get("Last-Modified")); errParse == nil {
+		repository.Version.LastModified = lastModified
+	}
+	return
+}
@@ -3,13 +3,18 @@ package cms
 import (
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // Client for a NEOS cms
 type Client struct {
 	// HTTP client used to communicate with NEOS CMS
-	client   *http.Client
-	Endpoint *url.URL
+	client           *http.Client
+	repositoryClient *http.Client // shares the transport, but allows long downloads
+	Endpoint         *url.URL
+
+	retries      int
+	retryBackoff time.Duration
 
 	CMS Service
 }
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/foomo/neosproxy/cache"
+	"github.com/foomo/neosproxy/cache/content/store"
+	"github.com/foomo/neosproxy/cache/snapshot"
+	"github.com/foomo/neosproxy/config"
+	"github.com/foomo/neosproxy/logging"
+	"github.com/sirupsen/logrus"
+)
+
+//-----------------------------------------------------------------------------
+// ~ Constants
+//-----------------------------------------------------------------------------
+
+const cacheUsage = `usage: neosproxy cache <command> [flags]
+
+commands:
+  export   --to snapshot.tar.zst [--config-file file] [--store spec]
+  import   --from snapshot.tar.zst [--config-file file] [--store spec]
+  migrate  --from spec --to spec [--exports-from dir --exports-to dir]
+
+a store spec is <type>[:<location>], e.g. fs:/var/data/neosproxy/content,
+bolt:/var/data/cache.db, sqlite:/var/data/cache.sqlite, redis:redis://host:6379/0,
+mongo:mongodb://host/neosproxy or memory`
+
+var errCacheUsage = errors.New("invalid cache command")
+
+// specOptions maps a store type to the option set by a store spec location
+var specOptions = map[string]string{
+	"fs":     store.OptionDirectory,
+	"bolt":   store.OptionPath,
+	"sqlite": store.OptionPath,
+	"redis":  store.OptionURL,
+	"mongo":  store.OptionURL,
+}
+
+//-----------------------------------------------------------------------------
+// ~ Commands
+//-----------------------------------------------------------------------------
+
+// runCacheCommand handles "neosproxy cache <export|import|migrate>"
+func runCacheCommand(args []string) error {
+	if len(args) == 0 {
+		return errCacheUsage
+	}
+
+	switch args[0] {
+	case "export":
+		return runCacheExport(args[1:])
+	case "import":
+		return runCacheImport(args[1:])
+	case "migrate":
+		return runCacheMigrate(args[1:])
+	}
+	return errCacheUsage
+}
+
+func runCacheExport(args []string) error {
+	flags := flag.NewFlagSet("cache export", flag.ExitOnError)
+	flagConfigFile := flags.String("config-file", "/etc/neosproxy/config.yaml", "absolute path to neosproxy config file")
+	flagStore := flags.String("store", "", "store spec, defaults to the configured store")
+	flagTo := flags.String("to", "", "snapshot file to write")
+	flags.Parse(args)
+
+	if *flagTo == "" {
+		return errors.New("cache export: flag --to required")
+	}
+
+	cfg, contentStore, errStore := loadStore(*flagConfigFile, *flagStore)
+	if errStore != nil {
+		return errStore
+	}
+
+	exports := map[string]string{}
+	for _, workspace := range cfg.Neos.Workspaces {
+		exports[workspace] = cache.ExportFilename(cfg.Cache.Directory, workspace)
+	}
+
+	start := time.Now()
+	file, errCreate := os.Create(*flagTo)
+	if errCreate != nil {
+		return errCreate
+	}
+
+	manifest, errExport := snapshot.Export(file, contentStore, exports)
+	if errClose := file.Close(); errExport == nil {
+		errExport = errClose
+	}
+	if errExport != nil {
+		os.Remove(*flagTo)
+		return errExport
+	}
+
+	logging.GetDefaultLogEntry().WithDuration(start).WithFields(logrus.Fields{
+		"file":       *flagTo,
+		"items":      manifest.Items,
+		"workspaces": manifest.Workspaces,
+	}).Info("cache snapshot exported")
+	return nil
+}
+
+func runCacheImport(args []string) error {
+	flags := flag.NewFlagSet("cache import", flag.ExitOnError)
+	flagConfigFile := flags.String("config-file", "/etc/neosproxy/config.yaml", "absolute path to neosproxy config file")
+	flagStore := flags.String("store", "", "store spec, defaults to the configured store")
+	flagFrom := flags.String("from", "", "snapshot file to read")
+	flags.Parse(args)
+
+	if *flagFrom == "" {
+		return errors.New("cache import: flag --from required")
+	}
+
+	cfg, contentStore, errStore := loadStore(*flagConfigFile, *flagStore)
+	if errStore != nil {
+		return errStore
+	}
+
+	start := time.Now()
+	file, errOpen := os.Open(*flagFrom)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer file.Close()
+
+	manifest, errImport := snapshot.Import(file, contentStore, func(workspace string) string {
+		return cache.ExportFilename(cfg.Cache.Directory, workspace)
+	})
+	if errImport != nil {
+		return errImport
+	}
+
+	logging.GetDefaultLogEntry().WithDuration(start).WithFields(logrus.Fields{
+		"file":       *flagFrom,
+		"items":      manifest.Items,
+		"workspaces": manifest.Workspaces,
+		"created":    manifest.Created,
+	}).Info("cache snapshot imported")
+	return nil
+}
+
+func runCacheMigrate(args []string) error {
+	flags := flag.NewFlagSet("cache migrate", flag.ExitOnError)
+	flagFrom := flags.String("from", "", "source store spec")
+	flagTo := flags.String("to", "", "target store spec")
+	flagExportsFrom := flags.String("exports-from", "", "source cache directory of contentserver exports (optional)")
+	flagExportsTo := flags.String("exports-to", "", "target cache directory of contentserver exports (optional)")
+	flags.Parse(args)
+
+	if *flagFrom == "" || *flagTo == "" {
+		return errors.New("cache migrate: flags --from and --to required")
+	}
+	if (*flagExportsFrom == "") != (*flagExportsTo == "") {
+		return errors.New("cache migrate: flags --exports-from and --exports-to must be used together")
+	}
+
+	from, errFrom := openStoreSpec(*flagFrom)
+	if errFrom != nil {
+		return errFrom
+	}
+	to, errTo := openStoreSpec(*flagTo)
+	if errTo != nil {
+		return errTo
+	}
+
+	start := time.Now()
+	count, errMigrate := snapshot.Migrate(from, to)
+	if errMigrate != nil {
+		return errMigrate
+	}
+
+	exports := 0
+	if *flagExportsFrom != "" {
+		var errExports error
+		exports, errExports = copyExports(cache.ExportDirectory(*flagExportsFrom), cache.ExportDirectory(*flagExportsTo))
+		if errExports != nil {
+			return errExports
+		}
+	}
+
+	logging.GetDefaultLogEntry().WithDuration(start).WithFields(logrus.Fields{
+		"from":    *flagFrom,
+		"to":      *flagTo,
+		"items":   count,
+		"exports": exports,
+	}).Info("cache migrated")
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// ~ Private methods
+//-----------------------------------------------------------------------------
+
+// loadStore loads the config and opens the configured store or the given store spec
+func loadStore(configFile string, spec string) (cfg *config.Config, s store.CacheStore, err error) {
+	cfg, err = config.Load(configFile)
+	if err != nil {
+		return
+	}
+	if spec != "" {
+		s, err = openStoreSpec(spec)
+		return
+	}
+	s, err = store.Open(cfg.Cache.Store.Type, cfg.Cache.Store.Options)
+	return
+}
+
+// openStoreSpec opens a store given as <type>[:<location>]
+func openStoreSpec(spec string) (store.CacheStore, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	name := parts[0]
+	options := map[string]string{}
+	if len(parts) == 2 && parts[1] != "" {
+		option, ok := specOptions[name]
+		if !ok {
+			return nil, fmt.Errorf("store %q does not take a location", name)
+		}
+		options[option] = parts[1]
+	}
+	return store.Open(name, options)
+}
+
+func copyExports(from string, to string) (count int, err error) {
+	files, errReadDir := ioutil.ReadDir(from)
+	if errReadDir != nil {
+		err = errReadDir
+		return
+	}
+	if err = os.MkdirAll(to, 0755); err != nil {
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		if err = copyFile(filepath.Join(from, file.Name()), filepath.Join(to, file.Name())); err != nil {
+			return
+		}
+		count++
+	}
+	return
+}
+
+func copyFile(from string, to string) error {
+	source, errOpen := os.Open(from)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer source.Close()
+
+	target, errCreate := os.Create(to + ".migrate")
+	if errCreate != nil {
+		return errCreate
+	}
+	if _, errCopy := io.Copy(target, source); errCopy != nil {
+		target.Close()
+		os.Remove(target.Name())
+		return errCopy
+	}
+	if errClose := target.Close(); errClose != nil {
+		os.Remove(target.Name())
+		return errClose
+	}
+	return os.Rename(target.Name(), to)
+}
@@ -1,20 +1,48 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"path/filepath"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/foomo/neosproxy/cache/content/store/fs"
+	"github.com/foomo/neosproxy/cache/content/store"
 	"github.com/foomo/neosproxy/client/cms"
 	"github.com/foomo/neosproxy/config"
 	"github.com/foomo/neosproxy/logging"
 	"github.com/foomo/neosproxy/proxy"
 	"github.com/sirupsen/logrus"
+
+	// content cache store backends
+	_ "github.com/foomo/neosproxy/cache/content/store/bolt"
+	_ "github.com/foomo/neosproxy/cache/content/store/fs"
+	_ "github.com/foomo/neosproxy/cache/content/store/memory"
+	_ "github.com/foomo/neosproxy/cache/content/store/mongostore"
+	_ "github.com/foomo/neosproxy/cache/content/store/redis"
 )
 
+// shutdownTimeout for running requests
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 
+	// cache maintenance commands
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		err := runCacheCommand(os.Args[2:])
+		if err == errCacheUsage {
+			fmt.Fprintln(os.Stderr, cacheUsage)
+			os.Exit(2)
+		}
+		if err != nil {
+			logging.GetDefaultLogEntry().WithError(err).Fatalln("cache command failed")
+		}
+		return
+	}
+
 	// parse flags
 	flagConfigFile := flag.String("config-file", "/etc/neosproxy/config.yaml", "absolute path to neosproxy config file")
 	flag.Parse()
@@ -44,46 +72,41 @@ func main() {
 	}
 
 	// create content cache store
-	// contentStore := memory.NewCacheStore()
-	contentStore := fs.NewCacheStore(filepath.Join(config.Cache.Directory, "content"))
-	cacheLifetime := time.Duration(0) // forever // time.Minute * 60
+	contentStore, errContentStore := store.Open(config.Cache.Store.Type, config.Cache.Store.Options)
+	if errContentStore != nil {
+		logger.WithError(errContentStore).WithField("store", config.Cache.Store.Type).Fatalln("failed to init content cache store")
+	}
 
 	// create proxy
-	p := proxy.New(config, contentLoader.CMS, contentStore, cacheLifetime)
-
-	// // auto update
-	// if config.Cache.AutoUpdateDuration != "" {
-	// 	autoUpdate, err := time.ParseDuration(config.Cache.AutoUpdateDuration)
-	// 	if err != nil {
-	// 		log.Fatal("invalid auto-update duration value: " + err.Error())
-	// 	}
-	// 	go func() {
-	// 		log.Println(config.Cache.AutoUpdateDuration, "auto update enabled")
-	// 		for {
-	// 			time.Sleep(autoUpdate)
-	// 			log.Println(fmt.Sprintf("auto update: updating %d cache", len(p.CacheInvalidationChannels)))
-	// 			for workspace, channel := range p.CacheInvalidationChannels {
-	// 				select {
-	// 				case channel <- time.Now():
-	// 					log.Println(fmt.Sprintf("auto update: added cache invalidation request to queue for '%s' workspace", workspace))
-	// 				default:
-	// 					log.Println(fmt.Sprintf("auto update: ignored cache invalidation request due to pending invalidation requests for '%s' workspace", workspace))
-	// 				}
-	// 			}
-	// 		}
-	// 	}()
-	// }
+	p := proxy.New(config, contentLoader.CMS, contentStore, config.Cache.Store.Lifetime)
 
 	// logging
 	logger.WithFields(logrus.Fields{
 		logging.FieldAddr: config.Proxy.Address,
 		"neos":            config.Neos.URL,
 		"cache":           config.Cache.Directory,
+		"store":           config.Cache.Store.Type,
 		"basepath":        config.Proxy.BasePath,
 	}).Info("run proxy server")
 
+	// graceful shutdown, running export downloads are cancelled
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-signals
+		logger.WithField("signal", sig.String()).Info("shutting down proxy server")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := p.Shutdown(ctx); err != nil {
+			logger.WithError(err).Error("failed shutting down proxy server")
+		}
+	}()
+
 	// run proxy
-	if err := p.Run(); err != nil {
+	if err := p.Run(); err != http.ErrServerClosed {
 		logger.WithError(err).Fatalln("failed running proxy server")
 	}
+	<-shutdown
 }
@@ -0,0 +1,7 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+// the sqlite driver requires cgo, builds without cgo do not offer the sqlite backend
+import _ "github.com/foomo/neosproxy/cache/content/store/sqlite"
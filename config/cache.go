@@ -0,0 +1,205 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/foomo/neosproxy/scheduler"
+)
+
+//-----------------------------------------------------------------------------
+// ~ Private methods
+//-----------------------------------------------------------------------------
+
+func newCache(conf *configFile) (cache Cache, err error) {
+	cache = Cache{
+		AutoUpdateDuration: conf.Cache.AutoUpdateDuration,
+		Directory:          conf.Cache.Directory,
+		History:            conf.Cache.History,
+		ExportHistory:      conf.Cache.ExportHistory,
+		Validation:         conf.Cache.Validation,
+		Store: CacheStore{
+			Type:    strings.ToLower(conf.Cache.Store.Type),
+			Options: map[string]string{},
+		},
+	}
+
+	if cache.History == 0 {
+		cache.History = DefaultCacheHistory
+	}
+
+	if cache.ExportHistory == 0 {
+		cache.ExportHistory = DefaultCacheExportHistory
+	}
+
+	if cache.Validation.MinNodes < 0 || cache.Validation.MaxShrink < 0 || cache.Validation.MaxShrink > 100 {
+		err = errors.New("invalid cache validation: minNodes must not be negative, maxShrink must be a percentage")
+		return
+	}
+
+	schedule, errSchedule := newSchedule(conf)
+	if errSchedule != nil {
+		err = errSchedule
+		return
+	}
+	cache.Schedule = schedule
+
+	startup, errStartup := newStartup(conf)
+	if errStartup != nil {
+		err = errStartup
+		return
+	}
+	cache.Startup = startup
+
+	debounce, errDebounce := newDebounce(conf.Cache.Debounce, Debounce{Wait: DefaultDebounceWait, MaxWait: DefaultDebounceMaxWait})
+	if errDebounce != nil {
+		err = errDebounce
+		return
+	}
+	debounce.Workspaces = map[string]Debounce{}
+	for workspace, workspaceConf := range conf.Cache.Debounce.Workspaces {
+		workspaceDebounce, errWorkspace := newDebounce(workspaceConf, debounce)
+		if errWorkspace != nil {
+			err = errors.New(errWorkspace.Error() + " of workspace " + workspace)
+			return
+		}
+		workspaceDebounce.Workspaces = nil
+		debounce.Workspaces[strings.ToLower(workspace)] = workspaceDebounce
+	}
+	cache.Debounce = debounce
+
+	if cache.Store.Type == "" {
+		cache.Store.Type = DefaultCacheStoreType
+	}
+
+	for key, value := range conf.Cache.Store.Options {
+		cache.Store.Options[strings.ToLower(key)] = value
+	}
+	if _, ok := cache.Store.Options["directory"]; !ok {
+		cache.Store.Options["directory"] = filepath.Join(cache.Directory, "content")
+	}
+
+	if conf.Cache.Store.Lifetime != "" {
+		lifetime, errLifetime := time.ParseDuration(conf.Cache.Store.Lifetime)
+		if errLifetime != nil {
+			err = errors.New("invalid cache store lifetime: " + errLifetime.Error())
+			return
+		}
+		cache.Store.Lifetime = lifetime
+	}
+
+	return
+}
+
+func newSchedule(conf *configFile) (schedule Schedule, err error) {
+	schedule = Schedule{
+		Exports:      map[string]string{},
+		Revalidation: conf.Cache.Schedule.Revalidation,
+		QuietWindows: []scheduler.QuietWindow{},
+	}
+
+	specs := []string{conf.Cache.AutoUpdateDuration, schedule.Revalidation}
+	for workspace, spec := range conf.Cache.Schedule.Exports {
+		schedule.Exports[strings.ToLower(workspace)] = spec
+		specs = append(specs, spec)
+	}
+	for _, spec := range specs {
+		if spec == "" {
+			continue
+		}
+		if _, errParse := scheduler.Parse(spec); errParse != nil {
+			err = errors.New("invalid cache schedule: " + errParse.Error())
+			return
+		}
+	}
+
+	for _, value := range conf.Cache.Schedule.QuietWindows {
+		window, errWindow := scheduler.ParseQuietWindow(value)
+		if errWindow != nil {
+			err = errors.New("invalid cache schedule: " + errWindow.Error() + ": " + value)
+			return
+		}
+		schedule.QuietWindows = append(schedule.QuietWindows, window)
+	}
+
+	if conf.Cache.Schedule.Jitter != "" {
+		jitter, errJitter := time.ParseDuration(conf.Cache.Schedule.Jitter)
+		if errJitter != nil || jitter < 0 {
+			err = errors.New("invalid cache schedule jitter: " + conf.Cache.Schedule.Jitter)
+			return
+		}
+		schedule.Jitter = jitter
+	}
+	return
+}
+
+func newStartup(conf *configFile) (startup Startup, err error) {
+	startup = Startup{
+		WaitForExports: conf.Cache.Startup.WaitForExports,
+		RetryInterval:  DefaultStartupRetryInterval,
+	}
+
+	if conf.Cache.Startup.WaitTimeout != "" {
+		timeout, errTimeout := time.ParseDuration(conf.Cache.Startup.WaitTimeout)
+		if errTimeout != nil || timeout < 0 {
+			err = errors.New("invalid cache startup wait timeout: " + conf.Cache.Startup.WaitTimeout)
+			return
+		}
+		startup.WaitTimeout = timeout
+	}
+
+	if conf.Cache.Startup.RetryInterval != "" {
+		interval, errInterval := time.ParseDuration(conf.Cache.Startup.RetryInterval)
+		if errInterval != nil || interval <= 0 {
+			err = errors.New("invalid cache startup retry interval: " + conf.Cache.Startup.RetryInterval)
+			return
+		}
+		startup.RetryInterval = interval
+	}
+	return
+}
+
+// newDebounce parses debounce settings, unset values are inherited
+func newDebounce(conf configFileDebounce, inherited Debounce) (debounce Debounce, err error) {
+	debounce = Debounce{
+		Wait:    inherited.Wait,
+		MaxWait: inherited.MaxWait,
+	}
+
+	if conf.Wait != "" {
+		wait, errWait := time.ParseDuration(conf.Wait)
+		if errWait != nil || wait < 0 {
+			err = errors.New("invalid cache debounce wait: " + conf.Wait)
+			return
+		}
+		debounce.Wait = wait
+	}
+
+	if conf.MaxWait != "" {
+		maxWait, errMaxWait := time.ParseDuration(conf.MaxWait)
+		if errMaxWait != nil || maxWait < 0 {
+			err = errors.New("invalid cache debounce max wait: " + conf.MaxWait)
+			return
+		}
+		debounce.MaxWait = maxWait
+	}
+
+	if debounce.MaxWait < debounce.Wait {
+		err = errors.New("invalid cache debounce: max wait " + debounce.MaxWait.String() + " is shorter than wait " + debounce.Wait.String())
+		return
+	}
+	return
+}
+
+// Workspace returns the debounce settings of a workspace
+func (d Debounce) Workspace(workspace string) Debounce {
+	if workspaceDebounce, ok := d.Workspaces[workspace]; ok {
+		return workspaceDebounce
+	}
+	return Debounce{
+		Wait:    d.Wait,
+		MaxWait: d.MaxWait,
+	}
+}
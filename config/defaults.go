@@ -1,3 +1,23 @@
 package config
 
+import "time"
+
 const DefaultWorkspace = "live"
+
+// DefaultCacheStoreType used for the content cache
+const DefaultCacheStoreType = "fs"
+
+// DefaultCacheHistory number of versions kept per cached document
+const DefaultCacheHistory = 5
+
+// DefaultCacheExportHistory number of contentserver exports kept per workspace
+const DefaultCacheExportHistory = 5
+
+// DefaultDebounceWait for more contentserver export refresh requests after the last one
+const DefaultDebounceWait = 5 * time.Second
+
+// DefaultDebounceMaxWait longest delay of a contentserver export refresh while requests keep coming in
+const DefaultDebounceMaxWait = 30 * time.Second
+
+// DefaultStartupRetryInterval between initial contentserver export loads of a workspace without an export
+const DefaultStartupRetryInterval = 30 * time.Second
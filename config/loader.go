@@ -46,12 +46,19 @@ func Load(filename string) (config *Config, err error) {
 	// create config value object
 	config = &Config{
 		Proxy:         conf.Proxy,
-		Cache:         conf.Cache,
 		Subscriptions: make(map[string][]string, len(conf.Subscriptions)),
 		Observer:      []*Observer{},
 	}
 	config.Neos.URL = neosURL
 
+	// cache
+	cache, errCache := newCache(conf)
+	if errCache != nil {
+		err = errCache
+		return
+	}
+	config.Cache = cache
+
 	// workspaces
 	workspaceNames := map[string]bool{}
 	config.Neos.Workspaces = make([]string, len(conf.Neos.Workspaces))
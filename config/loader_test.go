@@ -2,12 +2,14 @@ package config
 
 import (
 	"testing"
+	"time"
 
+	"github.com/foomo/neosproxy/scheduler"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConfigLoader(t *testing.T) {
-	cfg, errLoadCfg := Load("../config-example.yaml")
+	cfg, errLoadCfg := Load("testdata/config.yaml")
 	assert.NoError(t, errLoadCfg)
 	assert.NotNil(t, cfg)
 
@@ -23,6 +25,20 @@ func TestConfigLoader(t *testing.T) {
 
 	assert.Equal(t, "30m", cfg.Cache.AutoUpdateDuration)
 	assert.Equal(t, "/tmp/cache", cfg.Cache.Directory)
+	assert.Equal(t, 5, cfg.Cache.History)
+	assert.Equal(t, 5, cfg.Cache.ExportHistory)
+	assert.Equal(t, map[string]string{"stage": "*/5 * * * *"}, cfg.Cache.Schedule.Exports)
+	assert.Equal(t, "0 * * * *", cfg.Cache.Schedule.Revalidation)
+	assert.Equal(t, []scheduler.QuietWindow{{Start: 2 * time.Hour, End: 3 * time.Hour}}, cfg.Cache.Schedule.QuietWindows)
+	assert.Equal(t, time.Minute, cfg.Cache.Schedule.Jitter)
+	assert.Equal(t, Debounce{Wait: 5 * time.Second, MaxWait: 30 * time.Second}, cfg.Cache.Debounce.Workspace("live"))
+	assert.Equal(t, Debounce{Wait: time.Second, MaxWait: 30 * time.Second}, cfg.Cache.Debounce.Workspace("stage"))
+	assert.Equal(t, Startup{WaitForExports: false, WaitTimeout: 5 * time.Minute, RetryInterval: 30 * time.Second}, cfg.Cache.Startup)
+	assert.Equal(t, 1, cfg.Cache.Validation.MinNodes)
+	assert.Equal(t, 50.0, cfg.Cache.Validation.MaxShrink)
+	assert.Equal(t, "fs", cfg.Cache.Store.Type)
+	assert.Equal(t, time.Duration(0), cfg.Cache.Store.Lifetime)
+	assert.Equal(t, "/tmp/cache/content", cfg.Cache.Store.Options["directory"])
 
 	assert.Len(t, cfg.Subscriptions, 3)
 	assert.Contains(t, cfg.Subscriptions, "live")
@@ -53,3 +69,11 @@ func TestConfigLoader(t *testing.T) {
 	assert.Equal(t, ObserverTypeWebhook, cfg.Observer[3].Webhook.Type)
 	assert.NotEmpty(t, cfg.Observer[3].Webhook.Name)
 }
+
+func TestConfigExample(t *testing.T) {
+	cfg, errLoadCfg := Load("../config-example.yaml")
+	assert.NoError(t, errLoadCfg)
+	assert.Equal(t, "cms", cfg.Neos.URL.Hostname())
+	assert.Equal(t, []string{"live", "stage"}, cfg.Neos.Workspaces)
+	assert.Equal(t, "/var/data/neosproxy", cfg.Cache.Directory)
+}
@@ -1,6 +1,11 @@
 package config
 
-import "net/url"
+import (
+	"net/url"
+	"time"
+
+	"github.com/foomo/neosproxy/scheduler"
+)
 
 //-----------------------------------------------------------------------------
 // ~ Interface
@@ -31,6 +36,51 @@ type Config struct {
 type Cache struct {
 	AutoUpdateDuration string `json:"autoUpdateDuration" yaml:"autoUpdateDuration"`
 	Directory          string
+	History            int // number of versions kept per document (< 0 === disabled)
+	ExportHistory      int `json:"exportHistory" yaml:"exportHistory"` // number of contentserver exports kept per workspace (< 0 === disabled)
+	Validation         ExportValidation
+	Schedule           Schedule
+	Startup            Startup
+	Debounce           Debounce
+	Store              CacheStore
+}
+
+// Debounce config struct of contentserver export refreshes
+// requests are collected until none arrived for Wait, but a refresh is not delayed longer than MaxWait
+type Debounce struct {
+	Wait       time.Duration
+	MaxWait    time.Duration
+	Workspaces map[string]Debounce // per workspace settings, without nested workspaces
+}
+
+// Startup config struct of the initial contentserver export load of all workspaces
+type Startup struct {
+	WaitForExports bool          // delay serving until every workspace has a valid export
+	WaitTimeout    time.Duration // serve anyway after waiting this long (0 === wait forever)
+	RetryInterval  time.Duration // between loads of a workspace without an export
+}
+
+// Schedule config struct of periodic jobs, schedules are durations ("30m") or cron expressions ("*/15 * * * *")
+type Schedule struct {
+	Exports      map[string]string // workspace => export refresh schedule, defaults to AutoUpdateDuration
+	Revalidation string            // content cache revalidation sweep schedule (empty === disabled)
+	QuietWindows []scheduler.QuietWindow
+	Jitter       time.Duration // random delay of every run to spread replicas
+}
+
+// ExportValidation rules a downloaded contentserver export has to pass before it replaces the current one
+// all dimensions of Neos.Dimensions are required
+type ExportValidation struct {
+	Schema    string  // json schema file (empty === disabled)
+	MinNodes  int     `json:"minNodes" yaml:"minNodes"`   // minimum number of nodes of all dimensions
+	MaxShrink float64 `json:"maxShrink" yaml:"maxShrink"` // maximum percentage of nodes removed since the previous export (0 === disabled)
+}
+
+// CacheStore config struct for the content cache store backend
+type CacheStore struct {
+	Type     string
+	Lifetime time.Duration // default lifetime of cached content (0 === forever)
+	Options  map[string]string
 }
 
 // Neos config struct
@@ -90,11 +140,43 @@ type configFile struct {
 	Cache struct {
 		AutoUpdateDuration string `json:"autoUpdateDuration" yaml:"autoUpdateDuration"`
 		Directory          string
+		History            int
+		ExportHistory      int `json:"exportHistory" yaml:"exportHistory"`
+		Validation         ExportValidation
+		Schedule           configFileSchedule
+		Startup            configFileStartup
+		Debounce           configFileDebounce
+		Store              configFileCacheStore
 	}
 	Observer      []configFileObserver `json:"-" yaml:"observer"`
 	Subscriptions map[string][]string
 }
 
+type configFileSchedule struct {
+	Exports      map[string]string
+	Revalidation string
+	QuietWindows []string `json:"quietWindows" yaml:"quietWindows"`
+	Jitter       string
+}
+
+type configFileStartup struct {
+	WaitForExports bool   `json:"waitForExports" yaml:"waitForExports"`
+	WaitTimeout    string `json:"waitTimeout" yaml:"waitTimeout"`
+	RetryInterval  string `json:"retryInterval" yaml:"retryInterval"`
+}
+
+type configFileDebounce struct {
+	Wait       string
+	MaxWait    string `json:"maxWait" yaml:"maxWait"`
+	Workspaces map[string]configFileDebounce
+}
+
+type configFileCacheStore struct {
+	Type     string
+	Lifetime string
+	Options  map[string]string
+}
+
 type configFileObserver struct {
 	Name      string
 	Type      ObserverType
@@ -4,17 +4,24 @@ go 1.12
 
 require (
 	code.cloudfoundry.org/bytefmt v0.0.0-20190819182555-854d396b647c // indirect
+	github.com/alicebob/miniredis/v2 v2.11.4
+	github.com/andybalholm/brotli v1.0.5
 	github.com/auth0/go-jwt-middleware v1.0.1
 	github.com/cloudfoundry/bytefmt v0.0.0-20180906201452-2aa6f33b730c
 	github.com/foomo/shop v0.0.0-20190306093145-644b0b683ba1
 	github.com/form3tech-oss/jwt-go v3.2.5+incompatible // indirect
+	github.com/go-redis/redis/v7 v7.4.0
 	github.com/gorilla/mux v1.7.4
+	github.com/klauspost/compress v1.11.13
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/onsi/ginkgo v1.10.2 // indirect
-	github.com/onsi/gomega v1.7.0 // indirect
 	github.com/pkg/errors v0.0.0-20181023235946-059132a15dd0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/sirupsen/logrus v1.2.0
-	github.com/stretchr/testify v1.2.2
+	github.com/stretchr/testify v1.3.0
+	github.com/xeipuuv/gojsonschema v1.2.0
+	go.etcd.io/bbolt v1.3.6
 	golang.org/x/sync v0.0.0-20180314180146-1d60e4601c6f
 	gopkg.in/mgo.v2 v2.0.0-20180705113604-9856a29383ce
-	gopkg.in/yaml.v2 v2.2.2
+	gopkg.in/yaml.v2 v2.2.4
 )
@@ -26,10 +26,13 @@ type Report struct {
 	Hash      string
 	Workspace string
 
-	Messages struct {
-		Status  MessageStatus
-		NodeID  string
-		Message string
-		Data    map[string]string
-	}
+	Messages []Message
+}
+
+// Message of a report, optionally related to a node
+type Message struct {
+	Status  MessageStatus
+	NodeID  string
+	Message string
+	Data    map[string]string
 }
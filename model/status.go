@@ -1,7 +1,61 @@
 package model
 
+import "time"
+
 type Status struct {
 	Workspaces      []string
-	ProviderReports map[string]Report `json:"providerReports"`
-	ConsumerReports map[string]Report `json:"consumerReports"`
+	Ready           bool                    `json:"ready"` // every workspace has a valid export
+	ProviderReports map[string]Report       `json:"providerReports"`
+	ConsumerReports map[string]Report       `json:"consumerReports"`
+	ContentCache    ContentCacheStatus      `json:"contentCache"`
+	Exports         map[string]ExportStatus `json:"exports"`
+	Schedule        []ScheduledJobStatus    `json:"schedule"`
+	Disk            *DiskStatus             `json:"disk,omitempty"`
+}
+
+// ContentCacheStatus of the content cache and its store
+type ContentCacheStatus struct {
+	// Degraded is true while writes to the store fail, fresh content is served uncached
+	Degraded         bool      `json:"degraded"`
+	WriteErrors      uint64    `json:"writeErrors"`
+	LastWriteError   string    `json:"lastWriteError,omitempty"`
+	LastWriteErrorAt time.Time `json:"lastWriteErrorAt,omitempty"`
+
+	Items     int    `json:"items"`
+	Bytes     uint64 `json:"bytes,omitempty"`
+	Quota     uint64 `json:"quota,omitempty"`
+	Evictions uint64 `json:"evictions,omitempty"`
+}
+
+// ExportStatus of the cached contentserver export of a workspace
+type ExportStatus struct {
+	Hash        string    `json:"hash"`
+	Updated     time.Time `json:"updated,omitempty"`
+	Versions    int       `json:"versions"` // retained exports
+	Frozen      bool      `json:"frozen"`   // automatic refreshes are stopped
+	FrozenSince time.Time `json:"frozenSince,omitempty"`
+	Ready       bool      `json:"ready"`               // a valid export exists
+	LastError   string    `json:"lastError,omitempty"` // of the last failed export load
+	LastErrorAt time.Time `json:"lastErrorAt,omitempty"`
+
+	PendingRefreshes int       `json:"pendingRefreshes"` // requests waiting for the next refresh
+	PendingSince     time.Time `json:"pendingSince,omitempty"`
+	Refreshing       bool      `json:"refreshing"`
+	SkippedRefreshes uint64    `json:"skippedRefreshes"` // requests served by the refresh of another request
+}
+
+// ScheduledJobStatus of a periodic job
+type ScheduledJobStatus struct {
+	Name         string    `json:"name"`
+	Schedule     string    `json:"schedule"`
+	Next         time.Time `json:"next"`
+	Last         time.Time `json:"last,omitempty"`
+	LastDuration string    `json:"lastDuration,omitempty"`
+}
+
+// DiskStatus of the file system holding the cache directory
+type DiskStatus struct {
+	Directory string `json:"directory"`
+	Total     uint64 `json:"total"`
+	Free      uint64 `json:"free"`
 }
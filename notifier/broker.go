@@ -6,6 +6,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/foomo/neosproxy/cache"
 	"github.com/foomo/neosproxy/logging"
+	"github.com/foomo/neosproxy/model"
 
 	content_cache "github.com/foomo/neosproxy/cache/content"
 )
@@ -20,6 +21,9 @@ type Broker struct {
 
 	sitemapLock      *sync.RWMutex
 	sitemapObservers map[string][]Notifier
+
+	alertLock      *sync.RWMutex
+	alertObservers map[string][]Notifier
 }
 
 // NewBroker will create a new message broker to handle cache invalidation notifications
@@ -30,6 +34,9 @@ func NewBroker() *Broker {
 
 		sitemapLock:      &sync.RWMutex{},
 		sitemapObservers: map[string][]Notifier{},
+
+		alertLock:      &sync.RWMutex{},
+		alertObservers: map[string][]Notifier{},
 	}
 }
 
@@ -65,6 +72,29 @@ func (b *Broker) NotifyOnSitemapChange(workspace string) {
 	}
 }
 
+// NotifyOnExportRejected alerts observers in case a contentserver export did not pass validation
+func (b *Broker) NotifyOnExportRejected(workspace string, report model.Report) {
+	b.alertLock.RLock()
+	defer b.alertLock.RUnlock()
+
+	if observers, ok := b.alertObservers[workspace]; ok {
+
+		event := NotifyEvent{
+			EventType: EventTypeExportRejected,
+			Payload:   report,
+		}
+
+		for _, observer := range observers {
+			logging.GetDefaultLogEntry().WithFields(logrus.Fields{
+				"name":      observer.GetName(),
+				"workspace": workspace,
+			}).Debug("broker: NotifyOnExportRejected")
+
+			go observer.Notify(event)
+		}
+	}
+}
+
 func (b *Broker) RegisterContentObserver(workspace string, observer Notifier) {
 	b.contentLock.Lock()
 	defer b.contentLock.Unlock()
@@ -91,3 +121,16 @@ func (b *Broker) RegisterSitemapObserver(workspace string, observer Notifier) {
 	observers = append(observers, observer)
 	b.sitemapObservers[workspace] = observers
 }
+
+func (b *Broker) RegisterAlertObserver(workspace string, observer Notifier) {
+	b.alertLock.Lock()
+	defer b.alertLock.Unlock()
+
+	observers, ok := b.alertObservers[workspace]
+	if !ok {
+		observers = []Notifier{}
+	}
+
+	observers = append(observers, observer)
+	b.alertObservers[workspace] = observers
+}
@@ -1,24 +1,69 @@
 package notifier
 
-// 	case ChannelTypeSlack:
-// 		client := slack.NewClient(channel.URL.String())
-// 		field := slack.Field{
-// 			Title: "NEOS Content Export",
-// 			Value: strings.ToUpper(user) + " published a NEOS content export to " + strings.ToUpper(workspace),
-// 			Short: false,
-// 		}
-// 		fields := []slack.Field{field}
-// 		attachment := &slack.Attachment{
-// 			Fallback: strings.ToUpper(user) + " published a NEOS content export to " + strings.ToUpper(workspace),
-// 			Color:    "warning",
-// 			Fields:   fields,
-// 		}
-// 		attachments := []*slack.Attachment{attachment}
-// 		msg := &slack.Message{
-// 			Channel:     channel.SlackChannel,
-// 			IconEmoji:   ":ghost:",
-// 			UserName:    "neosproxy",
-// 			Attachments: attachments,
-// 		}
-// 		err := client.SendMessage(msg)
-// 		return err == nil
+import (
+	"net/url"
+	"strings"
+
+	"github.com/foomo/neosproxy/model"
+	"github.com/foomo/neosproxy/notifier/slack"
+)
+
+var _ Notifier = &Slack{}
+
+// Slack posts alerts to a slack incoming webhook
+type Slack struct {
+	name    string
+	channel string
+	client  *slack.Client
+}
+
+// NewSlackNotifier will create a new slack alert notifier
+func NewSlackNotifier(name string, endpoint *url.URL, channel string) *Slack {
+	return &Slack{
+		name:    name,
+		channel: channel,
+		client:  slack.NewClient(endpoint.String()),
+	}
+}
+
+func (n *Slack) GetName() string {
+	return n.name
+}
+
+// Notify sends rejected exports, other events are ignored
+func (n *Slack) Notify(event NotifyEvent) error {
+	report, ok := event.Payload.(model.Report)
+	if event.EventType != EventTypeExportRejected || !ok {
+		return nil
+	}
+
+	text := "NEOS content export of " + strings.ToUpper(report.Workspace) + " rejected, the previous export is still served"
+	fields := []slack.Field{}
+	for _, message := range report.Messages {
+		if message.Status != model.MessageStatusError {
+			continue
+		}
+		value := message.Message
+		for key, data := range message.Data {
+			value += " (" + key + ": " + data + ")"
+		}
+		fields = append(fields, slack.Field{
+			Value: value,
+			Short: false,
+		})
+	}
+
+	msg := &slack.Message{
+		Channel:   n.channel,
+		IconEmoji: ":ghost:",
+		UserName:  "neosproxy",
+	}
+	msg.AddAttachment(&slack.Attachment{
+		Fallback: text,
+		Color:    "danger",
+		Title:    text,
+		Text:     "hash: " + report.Hash,
+		Fields:   fields,
+	})
+	return n.client.SendMessage(msg)
+}
@@ -3,8 +3,9 @@ package notifier
 type EventType string
 
 const (
-	EventTypeSitemapUpdate EventType = "EventTypeSitemapUpdate"
-	EventTypeContentUpdate EventType = "EventTypeContentUpdate"
+	EventTypeSitemapUpdate  EventType = "EventTypeSitemapUpdate"
+	EventTypeContentUpdate  EventType = "EventTypeContentUpdate"
+	EventTypeExportRejected EventType = "EventTypeExportRejected"
 )
 
 type NotifyEvent struct {
@@ -2,8 +2,13 @@ package proxy
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,6 +18,8 @@ import (
 	"github.com/foomo/neosproxy/cache"
 	"github.com/foomo/neosproxy/client/cms"
 	"github.com/foomo/neosproxy/logging"
+	"github.com/foomo/neosproxy/model"
+	"github.com/foomo/neosproxy/utils"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
@@ -27,6 +34,11 @@ const (
 	mimeApplicationJSON mime = "application/json"
 )
 
+const (
+	defaultCacheListLimit = 100
+	maxCacheListLimit     = 1000
+)
+
 // ------------------------------------------------------------------------------------------------
 // ~ Proxy handler methods
 // ------------------------------------------------------------------------------------------------
@@ -52,25 +64,13 @@ func (p *Proxy) getContent(w http.ResponseWriter, r *http.Request) {
 		logging.FieldID:        id,
 	})
 
-	// etag cache handling
-	headerEtag := r.Header.Get("ETag")
-	if headerEtag != "" {
-		etag, errEtag := p.contentCache.GetEtag(store.GetHash(id, dimension, workspace))
-		if errEtag == nil && etag != "" && etag == headerEtag {
-			w.WriteHeader(http.StatusNotModified)
-			log.WithDuration(start).Debug("content not modified")
-			return
-		}
-	}
-
 	// try cache hit, invalidate in case of item not found
 	item, errCacheGet := p.contentCache.Get(id, dimension, workspace)
 	if errCacheGet != nil {
 
+		// unreadable store, try serving fresh content
 		if errCacheGet != content_cache.ErrorNotFound {
-			w.WriteHeader(http.StatusInternalServerError)
 			log.WithError(errCacheGet).Error("get cached content failed")
-			return
 		}
 
 		// invalidate content
@@ -86,22 +86,60 @@ func (p *Proxy) getContent(w http.ResponseWriter, r *http.Request) {
 		item = itemInvalidated
 	}
 
+	// conditional request, answered from the item to send the same validators as a full response
+	etag := item.GetEtag()
+	encoding := utils.NegotiateEncoding(r.Header.Get("Accept-Encoding"))
+	notModified, etagCondition := etagNotModified(r, etag)
+	if !etagCondition {
+		notModified = modifiedSinceNotModified(r, item.Created)
+	}
+	if notModified {
+		writeNotModified(w, entityTag(etag, encoding), item.Created)
+		log.WithDuration(start).Debug("content not modified")
+		return
+	}
+
 	// prepare response data
 	data := &cms.Content{
 		HTML:              item.HTML,
 		CacheDependencies: item.Dependencies,
 	}
-
-	w.Header().Set("ETag", item.GetEtag())
-
-	// stream json response
-	encoder := json.NewEncoder(w)
-	errEncode := encoder.Encode(data)
+	body, errEncode := json.Marshal(data)
 	if errEncode != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		log.WithError(errEncode).Error("json encoding failed")
 		return
 	}
+	body = append(body, '\n')
+
+	body, errCompress := compress(encoding, body)
+	if errCompress != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.WithError(errCompress).Error("compression failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Vary", "Accept-Encoding")
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Header().Set("ETag", entityTag(etag, encoding))
+	if !item.Created.IsZero() {
+		w.Header().Set("Last-Modified", item.Created.UTC().Format(http.TimeFormat))
+	}
+
+	// headers only
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	// stream json response
+	if _, errWrite := w.Write(body); errWrite != nil {
+		log.WithError(errWrite).Warn("writing response failed")
+		return
+	}
 
 	// done
 	// log.WithDuration(start).Debug("content served")
@@ -212,6 +250,7 @@ func (p *Proxy) invalidateCache(w http.ResponseWriter, r *http.Request) {
 }
 
 // streamCachedNeosContentServerExport will stream contentserver export
+// ?workspace=&dimension= streams a pre-computed slice with a single dimension
 func (p *Proxy) streamCachedNeosContentServerExport(w http.ResponseWriter, r *http.Request) {
 
 	// duration
@@ -219,6 +258,7 @@ func (p *Proxy) streamCachedNeosContentServerExport(w http.ResponseWriter, r *ht
 
 	// extract request data
 	workspace := strings.TrimSpace(strings.ToLower(r.URL.Query().Get("workspace")))
+	dimension := strings.TrimSpace(r.URL.Query().Get("dimension"))
 
 	// validate workspace
 	if workspace == "" {
@@ -227,6 +267,9 @@ func (p *Proxy) streamCachedNeosContentServerExport(w http.ResponseWriter, r *ht
 
 	// logger
 	log := p.setupLogger(r, "streamCachedNeosContentServerExport").WithField(logging.FieldWorkspace, workspace)
+	if dimension != "" {
+		log = log.WithField(logging.FieldDimension, dimension)
+	}
 
 	workspaceCache, workspaceWorkerOK := p.workspaceCaches[workspace]
 	if !workspaceWorkerOK {
@@ -235,35 +278,89 @@ func (p *Proxy) streamCachedNeosContentServerExport(w http.ResponseWriter, r *ht
 		return
 	}
 
-	workspaceCache.FileLock.RLock()
-	defer workspaceCache.FileLock.RUnlock()
+	// current version of the export, it stays readable while a new one is published
+	export, errExport := workspaceCache.AcquireDimensionExport(dimension)
+	if errExport == cache.ErrorDimensionNotExists {
+		log.Info("cached contentserver export: dimension not found")
+		p.error(w, r, http.StatusNotFound, "dimension not found")
+		return
+	}
+	if errExport != nil {
+		workspaceCache.Invalidate()
+		log.WithError(errExport).Error("cached contentserver export: cache empty, invalidation triggered")
+		p.error(w, r, http.StatusConflict, "cache empty; cache invalidation triggered; please try again later")
+		return
+	}
+	defer export.Release()
+	hash := export.Hash
 
-	// open file
-	file, fileInfo, errFile := workspaceCache.GetContentServerExport()
-	if errFile != nil {
+	// set header
+	encoding := utils.NegotiateEncoding(r.Header.Get("Accept-Encoding"))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, must-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
 
-		if errFile == cache.ErrorFileNotExists {
-			workspaceCache.Invalidate()
-			log.WithError(errFile).Error("cached contentserver export: cache empty, invalidation triggered")
-			p.error(w, r, http.StatusConflict, "cache empty; cache invalidation triggered; please try again later")
-			return
-		}
+	// conditional request
+	notModified, etagCondition := etagNotModified(r, hash)
+	if !etagCondition {
+		notModified = modifiedSinceNotModified(r, export.ModTime)
+	}
+	if notModified {
+		writeNotModified(w, entityTag(hash, encoding), export.ModTime)
+		log.WithDuration(start).Debug("cached contentserver export not modified")
+		return
+	}
+
+	w.Header().Set("ETag", entityTag(hash, encoding))
+	w.Header().Set("Vary", "Accept-Encoding")
 
+	// open pre-compressed file, if available
+	precompressed := false
+	var file *os.File
+	var errFile error
+	if encoding != "" {
+		file, errFile = export.OpenCompressed(encoding)
+		precompressed = errFile == nil
+	}
+
+	// open file
+	if !precompressed {
+		file, errFile = export.Open()
+	}
+	if errFile != nil {
 		log.WithError(errFile).Error("cached contentserver export: read file failed")
 		p.error(w, r, http.StatusInternalServerError, "cached contentserver export: read file failed")
 		return
 	}
 	defer file.Close()
 
-	// set header
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Header().Set("Last-Modified", fileInfo.ModTime().Format(http.TimeFormat))
-	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	w.Header().Set("Pragma", "no-cache")
-	w.Header().Set("Expires", "0")
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+
+	// serve file with range support
+	if encoding == "" || precompressed {
+		http.ServeContent(w, r, "", export.ModTime, file)
+		log.WithDuration(start).WithField("encoding", encoding).WithField("range", r.Header.Get("Range")).Info("served file")
+		return
+	}
+
+	// compress on the fly, if there is no pre-compressed file, range requests will be ignored
+	w.Header().Set("Last-Modified", export.ModTime.UTC().Format(http.TimeFormat))
+	if r.Method == http.MethodHead {
+		return
+	}
+	compressor, errCompressor := utils.NewCompressor(encoding, w, utils.CompressionFast)
+	if errCompressor != nil {
+		log.WithError(errCompressor).Error("cached contentserver export: compression failed")
+		p.error(w, r, http.StatusInternalServerError, "cached contentserver export: compression failed")
+		return
+	}
+	defer compressor.Close()
 
 	// stream file
-	written, errFileStreaming := io.Copy(w, file)
+	written, errFileStreaming := io.Copy(compressor, file)
 	if errFileStreaming != nil {
 		log.WithError(errFileStreaming).WithField("size", bytefmt.ByteSize(uint64(written))).Error("cached contentserver export: file stream failed")
 		p.error(w, r, http.StatusInternalServerError, "cached contentserver export: file stream failed")
@@ -271,7 +368,147 @@ func (p *Proxy) streamCachedNeosContentServerExport(w http.ResponseWriter, r *ht
 	}
 
 	// log stats
-	log.WithDuration(start).WithField("size", bytefmt.ByteSize(uint64(written))).Info("streamed file")
+	log.WithDuration(start).WithField("size", bytefmt.ByteSize(uint64(written))).WithField("encoding", encoding).Info("streamed file")
+}
+
+// getContentServerExportDelta returns all nodes added, removed or changed since a retained export
+// ?workspace=&since=<hash>
+func (p *Proxy) getContentServerExportDelta(w http.ResponseWriter, r *http.Request) {
+
+	// duration
+	start := time.Now()
+
+	// extract request data
+	workspace := strings.TrimSpace(strings.ToLower(r.URL.Query().Get("workspace")))
+	since := strings.Trim(strings.TrimSpace(r.URL.Query().Get("since")), `"`)
+
+	// validate workspace
+	if workspace == "" {
+		workspace = cms.WorkspaceLive
+	}
+
+	// logger
+	log := p.setupLogger(r, "getContentServerExportDelta").WithFields(logrus.Fields{
+		logging.FieldWorkspace: workspace,
+		"since":                since,
+	})
+
+	if since == "" {
+		p.error(w, r, http.StatusBadRequest, "missing since parameter")
+		return
+	}
+
+	workspaceCache, workspaceWorkerOK := p.workspaceCaches[workspace]
+	if !workspaceWorkerOK {
+		log.Error("workspace worker not found")
+		p.error(w, r, http.StatusBadRequest, "workspace worker not found")
+		return
+	}
+
+	delta, errDelta := workspaceCache.GetExportDelta(since)
+	if errDelta != nil {
+		switch errDelta {
+		case cache.ErrorFileNotExists:
+			p.error(w, r, http.StatusConflict, "cache empty; please try again later")
+		case cache.ErrorExportNotInHistory:
+			log.Info("contentserver export not in history")
+			p.error(w, r, http.StatusGone, "contentserver export not in history; reload the full export")
+		default:
+			log.WithError(errDelta).Error("failed calculating contentserver export delta")
+			p.error(w, r, http.StatusInternalServerError, "failed calculating contentserver export delta")
+		}
+		return
+	}
+
+	encoding := utils.NegotiateEncoding(r.Header.Get("Accept-Encoding"))
+	body, errEncode := json.Marshal(delta)
+	if errEncode == nil {
+		body, errEncode = compress(encoding, append(body, '\n'))
+	}
+	if errEncode != nil {
+		log.WithError(errEncode).Error("failed encoding contentserver export delta")
+		p.error(w, r, http.StatusInternalServerError, "failed encoding contentserver export delta")
+		return
+	}
+
+	w.Header().Set("Content-Type", string(mimeApplicationJSON))
+	w.Header().Set("Vary", "Accept-Encoding")
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Write(body)
+
+	log.WithDuration(start).WithField("to", delta.To).Debug("served contentserver export delta")
+}
+
+// getNode returns a node of the contentserver export with its parents and children
+func (p *Proxy) getNode(w http.ResponseWriter, r *http.Request) {
+
+	// extract request data
+	id, dimension, workspace := getDocumentParameters(r)
+
+	// logger
+	log := p.setupLogger(r, "getNode").WithFields(logrus.Fields{
+		logging.FieldWorkspace: workspace,
+		logging.FieldDimension: dimension,
+		logging.FieldID:        id,
+	})
+
+	workspaceCache, workspaceWorkerOK := p.workspaceCaches[workspace]
+	if !workspaceWorkerOK {
+		p.error(w, r, http.StatusBadRequest, "workspace worker not found")
+		return
+	}
+
+	details, hash, errNode := workspaceCache.GetNode(dimension, id)
+	if errNode != nil {
+		p.nodeLookupError(w, r, log, errNode)
+		return
+	}
+
+	p.writeJSON(w, r, log, nodeResponse{
+		Workspace:   workspace,
+		Hash:        hash,
+		NodeDetails: details,
+	})
+}
+
+// resolveURI returns id and dimension of the node of an uri
+// ?uri=/de/products&workspace=&dimension=, all dimensions are searched without dimension
+func (p *Proxy) resolveURI(w http.ResponseWriter, r *http.Request) {
+
+	// extract request data
+	uri := r.URL.Query().Get("uri")
+	dimension := strings.TrimSpace(r.URL.Query().Get("dimension"))
+
+	workspaceCache, workspace, ok := p.getWorkspaceCache(w, r)
+	if !ok {
+		return
+	}
+
+	// logger
+	log := p.setupLogger(r, "resolveURI").WithFields(logrus.Fields{
+		logging.FieldWorkspace: workspace,
+		"uri":                  uri,
+	})
+
+	if uri == "" {
+		p.error(w, r, http.StatusBadRequest, "missing uri parameter")
+		return
+	}
+
+	node, nodeDimension, hash, errResolve := workspaceCache.ResolveURI(uri, dimension)
+	if errResolve != nil {
+		p.nodeLookupError(w, r, log, errResolve)
+		return
+	}
+
+	p.writeJSON(w, r, log, resolveResponse{
+		Workspace:   workspace,
+		Hash:        hash,
+		Dimension:   nodeDimension,
+		NodeSummary: node,
+	})
 }
 
 func (p *Proxy) streamStatus(w http.ResponseWriter, r *http.Request) {
@@ -279,6 +516,35 @@ func (p *Proxy) streamStatus(w http.ResponseWriter, r *http.Request) {
 	// logger
 	log := p.setupLogger(r, "status")
 
+	// current state of content cache and disk
+	status := *p.status
+	status.ContentCache = p.contentCache.Status()
+	status.Exports = map[string]model.ExportStatus{}
+	if p.scheduler != nil {
+		status.Schedule = p.scheduler.Status()
+	}
+	status.ProviderReports = map[string]model.Report{}
+	for name, report := range p.status.ProviderReports {
+		status.ProviderReports[name] = report
+	}
+	status.Ready = true
+	for workspace, workspaceCache := range p.workspaceCaches {
+		status.Exports[workspace] = workspaceCache.Status()
+		status.Ready = status.Ready && status.Exports[workspace].Ready
+		if report, ok := workspaceCache.GetReport(); ok {
+			status.ProviderReports[workspace] = report
+		}
+	}
+	if total, free, errDisk := utils.DiskSpace(p.config.Cache.Directory); errDisk == nil {
+		status.Disk = &model.DiskStatus{
+			Directory: p.config.Cache.Directory,
+			Total:     total,
+			Free:      free,
+		}
+	} else {
+		log.WithError(errDisk).Warn("failed reading disk space")
+	}
+
 	// stream
 	var errEncode error
 	contentNegotioation := parseAcceptHeader(r.Header.Get("accept"))
@@ -286,11 +552,11 @@ func (p *Proxy) streamStatus(w http.ResponseWriter, r *http.Request) {
 	case mimeApplicationJSON:
 		w.Header().Set("Content-Type", string(mimeApplicationJSON))
 		encoder := json.NewEncoder(w)
-		errEncode = encoder.Encode(p.status)
+		errEncode = encoder.Encode(status)
 	case mimeTextPlain:
 		w.Header().Set("Content-Type", "application/x-yaml")
 		encoder := yaml.NewEncoder(w)
-		errEncode = encoder.Encode(p.status)
+		errEncode = encoder.Encode(status)
 	}
 
 	// error handling
@@ -316,10 +582,12 @@ func (p *Proxy) getAllEtags(w http.ResponseWriter, r *http.Request) {
 	log := p.setupLogger(r, "getAllEtags").WithField(logging.FieldWorkspace, workspace)
 
 	etags := p.contentCache.GetAllEtags(workspace)
+	encoding := utils.NegotiateEncoding(r.Header.Get("Accept-Encoding"))
 
-	w.Header().Set("Content-Type", string(mimeApplicationJSON))
-	encoder := json.NewEncoder(w)
-	errEncode := encoder.Encode(etags)
+	body, errEncode := json.Marshal(etags)
+	if errEncode == nil {
+		body, errEncode = compress(encoding, append(body, '\n'))
+	}
 
 	// error handling
 	if errEncode != nil {
@@ -328,6 +596,13 @@ func (p *Proxy) getAllEtags(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("Content-Type", string(mimeApplicationJSON))
+	w.Header().Set("Vary", "Accept-Encoding")
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Write(body)
+
 	return
 }
 
@@ -345,7 +620,7 @@ func (p *Proxy) getEtag(w http.ResponseWriter, r *http.Request, hash string) {
 	}
 
 	w.Header().Set("Content-Type", string(mimeTextPlain))
-	w.Header().Set("ETag", etag)
+	w.Header().Set("ETag", quoteEtag(etag))
 	w.Write([]byte(etag))
 
 	return
@@ -374,13 +649,577 @@ func (p *Proxy) getEtagByHash(w http.ResponseWriter, r *http.Request) {
 	// extract request data
 	hash := getRequestParameter(r, "hash")
 
+	// validate hash
+	if _, errKey := store.ParseKey(hash); errKey != nil {
+		p.error(w, r, http.StatusBadRequest, errKey.Error())
+		return
+	}
+
 	p.getEtag(w, r, hash)
 }
 
+// listCache will list metadata of cached items
+// ?workspace=&dimension=&olderThan=24h|2006-01-02T15:04:05Z&limit=100&cursor=
+func (p *Proxy) listCache(w http.ResponseWriter, r *http.Request) {
+
+	// logger
+	log := p.setupLogger(r, "listCache")
+
+	query, errQuery := parseCacheQuery(r, time.Now())
+	if errQuery != nil {
+		p.error(w, r, http.StatusBadRequest, errQuery.Error())
+		return
+	}
+
+	// fetch one more item to find out about a next page
+	limit := query.Limit
+	query.Limit++
+	infos, errList := p.contentCache.List(query)
+	if errList != nil {
+		log.WithError(errList).Error("failed listing cache items")
+		p.error(w, r, http.StatusInternalServerError, "failed listing cache items")
+		return
+	}
+
+	response := cacheListResponse{Items: []cacheItemSummary{}}
+	if len(infos) > limit {
+		infos = infos[:limit]
+		response.Cursor = infos[limit-1].Hash
+	}
+	for _, info := range infos {
+		response.Items = append(response.Items, cacheItemSummary{
+			Hash:            info.Hash,
+			ID:              info.ID,
+			Dimension:       info.Dimension,
+			Workspace:       info.Workspace,
+			Etag:            info.Etag,
+			Size:            info.Size,
+			Created:         info.Created,
+			ValidUntil:      info.ValidUntil,
+			DependencyCount: len(info.Dependencies),
+		})
+	}
+
+	w.Header().Set("Content-Type", string(mimeApplicationJSON))
+	encoder := json.NewEncoder(w)
+	errEncode := encoder.Encode(response)
+
+	// error handling
+	if errEncode != nil {
+		log.WithError(errEncode).Error("failed encoding cache items")
+		http.Error(w, "failed encoding cache items", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getCacheItem will return the metadata of a cached item
+func (p *Proxy) getCacheItem(w http.ResponseWriter, r *http.Request) {
+
+	// extract request data
+	id, dimension, workspace := getDocumentParameters(r)
+
+	// logger
+	log := p.setupLogger(r, "getCacheItem").WithFields(logrus.Fields{
+		logging.FieldWorkspace: workspace,
+		logging.FieldID:        id,
+	})
+
+	info, errInfo := p.contentCache.GetInfo(id, dimension, workspace)
+	if errInfo != nil {
+		if errInfo == content_cache.ErrorNotFound {
+			p.error(w, r, http.StatusNotFound, errInfo.Error())
+			return
+		}
+		log.WithError(errInfo).Error("get cached content failed")
+		p.error(w, r, http.StatusInternalServerError, "get cached content failed")
+		return
+	}
+
+	details := cacheItemDetails{
+		Hash:         info.Hash,
+		ID:           info.ID,
+		Dimension:    info.Dimension,
+		Workspace:    info.Workspace,
+		Etag:         info.Etag,
+		Size:         info.Size,
+		Created:      info.Created,
+		ValidUntil:   info.ValidUntil,
+		Dependencies: info.Dependencies,
+	}
+	if details.Dependencies == nil {
+		details.Dependencies = []string{}
+	}
+	versions, errVersions := p.contentCache.GetVersions(id, dimension, workspace)
+	if errVersions != nil {
+		log.WithError(errVersions).Error("get versions failed")
+		p.error(w, r, http.StatusInternalServerError, "get versions failed")
+		return
+	}
+	for _, version := range versions {
+		details.Versions++
+		if version.Pinned {
+			details.PinnedVersion = version.Version
+		}
+	}
+
+	w.Header().Set("Content-Type", string(mimeApplicationJSON))
+	encoder := json.NewEncoder(w)
+	errEncode := encoder.Encode(details)
+
+	// error handling
+	if errEncode != nil {
+		log.WithError(errEncode).Error("failed encoding cache item")
+		http.Error(w, "failed encoding cache item", http.StatusInternalServerError)
+		return
+	}
+}
+
+// diffUpstream will stream a unified diff between the cached and the current upstream rendering of a document
+// the upstream rendering will not be cached
+func (p *Proxy) diffUpstream(w http.ResponseWriter, r *http.Request) {
+
+	// extract request data
+	id, dimension, workspace := getDocumentParameters(r)
+
+	// logger
+	log := p.setupLogger(r, "diffUpstream").WithFields(logrus.Fields{
+		logging.FieldWorkspace: workspace,
+		logging.FieldID:        id,
+	})
+
+	cached, errCacheGet := p.contentCache.Peek(id, dimension, workspace)
+	if errCacheGet != nil && errCacheGet != content_cache.ErrorNotFound {
+		log.WithError(errCacheGet).Error("get cached content failed")
+		p.error(w, r, http.StatusInternalServerError, "get cached content failed")
+		return
+	}
+
+	upstream, errFetch := p.contentCache.Fetch(id, dimension, workspace)
+	if errFetch != nil {
+		log.WithError(errFetch).Error("fetching upstream content failed")
+		p.error(w, r, http.StatusBadGateway, "fetching upstream content failed")
+		return
+	}
+
+	cachedEtag := "not cached"
+	if errCacheGet == nil {
+		cachedEtag = cached.GetEtag()
+	}
+	added, removed := diffDependencies(cached.Dependencies, upstream.Dependencies)
+
+	w.Header().Set("Content-Type", string(mimeTextPlain))
+	fmt.Fprintf(w, "etag cached:   %s\n", cachedEtag)
+	fmt.Fprintf(w, "etag upstream: %s\n", upstream.GetEtag())
+	fmt.Fprintf(w, "dependencies added:   %s\n", strings.Join(added, ", "))
+	fmt.Fprintf(w, "dependencies removed: %s\n", strings.Join(removed, ", "))
+	fmt.Fprintln(w)
+	w.Write([]byte(utils.UnifiedDiff("cached", "upstream", cached.HTML, upstream.HTML)))
+}
+
+// ------------------------------------------------------------------------------------------------
+// ~ Version history handler methods
+// ------------------------------------------------------------------------------------------------
+
+// getVersions will list the version history of a cached document
+func (p *Proxy) getVersions(w http.ResponseWriter, r *http.Request) {
+
+	// extract request data
+	id, dimension, workspace := getDocumentParameters(r)
+
+	// logger
+	log := p.setupLogger(r, "getVersions").WithFields(logrus.Fields{
+		logging.FieldWorkspace: workspace,
+		logging.FieldID:        id,
+	})
+
+	versions, errVersions := p.contentCache.GetVersions(id, dimension, workspace)
+	if errVersions != nil {
+		log.WithError(errVersions).Error("get versions failed")
+		p.error(w, r, http.StatusInternalServerError, "get versions failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", string(mimeApplicationJSON))
+	encoder := json.NewEncoder(w)
+	errEncode := encoder.Encode(versions)
+
+	// error handling
+	if errEncode != nil {
+		log.WithError(errEncode).Error("failed encoding versions")
+		http.Error(w, "failed encoding versions", http.StatusInternalServerError)
+		return
+	}
+}
+
+// diffVersions will stream a unified diff between two versions of a cached document
+// the version "to" defaults to the latest version
+func (p *Proxy) diffVersions(w http.ResponseWriter, r *http.Request) {
+
+	// extract request data
+	id, dimension, workspace := getDocumentParameters(r)
+
+	// logger
+	log := p.setupLogger(r, "diffVersions").WithFields(logrus.Fields{
+		logging.FieldWorkspace: workspace,
+		logging.FieldID:        id,
+	})
+
+	from, errFrom := strconv.Atoi(r.URL.Query().Get("from"))
+	if errFrom != nil {
+		p.error(w, r, http.StatusBadRequest, "invalid version: from")
+		return
+	}
+
+	to := 0
+	if r.URL.Query().Get("to") != "" {
+		var errTo error
+		to, errTo = strconv.Atoi(r.URL.Query().Get("to"))
+		if errTo != nil {
+			p.error(w, r, http.StatusBadRequest, "invalid version: to")
+			return
+		}
+	} else {
+		versions, errVersions := p.contentCache.GetVersions(id, dimension, workspace)
+		if errVersions != nil {
+			log.WithError(errVersions).Error("get versions failed")
+			p.error(w, r, http.StatusInternalServerError, "get versions failed")
+			return
+		}
+		if len(versions) > 0 {
+			to = versions[len(versions)-1].Version
+		}
+	}
+
+	versionFrom, errVersionFrom := p.contentCache.GetVersion(id, dimension, workspace, from)
+	versionTo, errVersionTo := p.contentCache.GetVersion(id, dimension, workspace, to)
+	if errVersionFrom == content_cache.ErrorVersionNotFound || errVersionTo == content_cache.ErrorVersionNotFound {
+		log.WithField("from", from).WithField("to", to).Info("version not found")
+		p.error(w, r, http.StatusNotFound, content_cache.ErrorVersionNotFound.Error())
+		return
+	}
+	if errVersionFrom != nil || errVersionTo != nil {
+		log.WithField("from", errVersionFrom).WithField("to", errVersionTo).Error("get versions failed")
+		p.error(w, r, http.StatusInternalServerError, "get versions failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", string(mimeTextPlain))
+	w.Write([]byte(utils.UnifiedDiff(
+		fmt.Sprintf("version %d (%s)", versionFrom.Version, versionFrom.Etag),
+		fmt.Sprintf("version %d (%s)", versionTo.Version, versionTo.Etag),
+		versionFrom.HTML,
+		versionTo.HTML,
+	)))
+}
+
+// rollbackVersion will replace a cached document with a previous version until the next invalidation
+func (p *Proxy) rollbackVersion(w http.ResponseWriter, r *http.Request) {
+
+	// extract request data
+	id, dimension, workspace := getDocumentParameters(r)
+	user := r.Header.Get("X-User")
+
+	// logger
+	log := p.setupLogger(r, "rollbackVersion").WithFields(logrus.Fields{
+		logging.FieldWorkspace: workspace,
+		logging.FieldID:        id,
+		"user":                 user,
+	})
+
+	version, errVersion := strconv.Atoi(getRequestParameter(r, "version"))
+	if errVersion != nil {
+		p.error(w, r, http.StatusBadRequest, "invalid version")
+		return
+	}
+
+	item, errRollback := p.contentCache.Rollback(id, dimension, workspace, version)
+	if errRollback != nil {
+		if errRollback == content_cache.ErrorVersionNotFound {
+			p.error(w, r, http.StatusNotFound, errRollback.Error())
+			return
+		}
+		log.WithError(errRollback).Error("rollback failed")
+		p.error(w, r, http.StatusInternalServerError, "rollback failed")
+		return
+	}
+
+	w.Header().Set("ETag", quoteEtag(item.GetEtag()))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("rolled back to version " + strconv.Itoa(version) + "\n"))
+	log.WithField("version", version).Info("rolled back to version")
+}
+
+// getReady reports whether every workspace has a valid contentserver export, 503 otherwise
+func (p *Proxy) getReady(w http.ResponseWriter, r *http.Request) {
+
+	// logger
+	log := p.setupLogger(r, "getReady")
+
+	response := readyResponse{
+		Ready:      true,
+		Workspaces: make(map[string]bool, len(p.workspaceCaches)),
+	}
+	for workspace, workspaceCache := range p.workspaceCaches {
+		response.Workspaces[workspace] = workspaceCache.IsReady()
+		response.Ready = response.Ready && response.Workspaces[workspace]
+	}
+
+	w.Header().Set("Content-Type", string(mimeApplicationJSON))
+	w.Header().Set("Cache-Control", "no-cache, must-revalidate")
+	if !response.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if errEncode := json.NewEncoder(w).Encode(response); errEncode != nil {
+		log.WithError(errEncode).Error("failed encoding readiness")
+	}
+}
+
+// getExportHistory will list the retained contentserver exports of a workspace
+func (p *Proxy) getExportHistory(w http.ResponseWriter, r *http.Request) {
+
+	// logger
+	log := p.setupLogger(r, "getExportHistory")
+
+	workspaceCache, workspace, ok := p.getWorkspaceCache(w, r)
+	if !ok {
+		return
+	}
+
+	response := exportHistoryResponse{
+		Workspace: workspace,
+		Status:    workspaceCache.Status(),
+		Versions:  workspaceCache.GetExportHistory(),
+	}
+
+	w.Header().Set("Content-Type", string(mimeApplicationJSON))
+	encoder := json.NewEncoder(w)
+	errEncode := encoder.Encode(response)
+
+	// error handling
+	if errEncode != nil {
+		log.WithError(errEncode).Error("failed encoding export history")
+		http.Error(w, "failed encoding export history", http.StatusInternalServerError)
+		return
+	}
+}
+
+// rollbackExport will replace the contentserver export of a workspace with a retained one
+// ?workspace=&freeze=true stops automatic refreshes until the workspace is unfrozen
+func (p *Proxy) rollbackExport(w http.ResponseWriter, r *http.Request) {
+
+	// extract request data
+	hash := getRequestParameter(r, "hash")
+	freeze, _ := strconv.ParseBool(r.URL.Query().Get("freeze"))
+	user := r.Header.Get("X-User")
+
+	workspaceCache, workspace, ok := p.getWorkspaceCache(w, r)
+	if !ok {
+		return
+	}
+
+	// logger
+	log := p.setupLogger(r, "rollbackExport").WithFields(logrus.Fields{
+		logging.FieldWorkspace: workspace,
+		"hash":                 hash,
+		"freeze":               freeze,
+		"user":                 user,
+	})
+
+	// freeze first, a running refresh must not replace the rolled back export
+	wasFrozen, _ := workspaceCache.Frozen()
+	if freeze {
+		if errFreeze := workspaceCache.Freeze(); errFreeze != nil {
+			log.WithError(errFreeze).Error("freeze failed")
+			p.error(w, r, http.StatusInternalServerError, "freeze failed")
+			return
+		}
+	}
+
+	if errRollback := workspaceCache.Rollback(hash); errRollback != nil {
+		if freeze && !wasFrozen {
+			workspaceCache.Unfreeze()
+		}
+		if errRollback == cache.ErrorExportNotInHistory {
+			p.error(w, r, http.StatusNotFound, errRollback.Error())
+			return
+		}
+		log.WithError(errRollback).Error("rollback failed")
+		p.error(w, r, http.StatusInternalServerError, "rollback failed")
+		return
+	}
+
+	w.Header().Set("ETag", quoteEtag(hash))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("rolled back to export " + hash + "\n"))
+	log.Info("rolled back export")
+}
+
+// freezeExport stops automatic refreshes of the contentserver export of a workspace
+func (p *Proxy) freezeExport(w http.ResponseWriter, r *http.Request) {
+	p.setExportFrozen(w, r, true)
+}
+
+// unfreezeExport resumes automatic refreshes of the contentserver export of a workspace
+func (p *Proxy) unfreezeExport(w http.ResponseWriter, r *http.Request) {
+	p.setExportFrozen(w, r, false)
+}
+
 // ------------------------------------------------------------------------------------------------
 // ~ Private methods
 // ------------------------------------------------------------------------------------------------
 
+func (p *Proxy) setExportFrozen(w http.ResponseWriter, r *http.Request, frozen bool) {
+
+	workspaceCache, workspace, ok := p.getWorkspaceCache(w, r)
+	if !ok {
+		return
+	}
+
+	// logger
+	log := p.setupLogger(r, "setExportFrozen").WithFields(logrus.Fields{
+		logging.FieldWorkspace: workspace,
+		"frozen":               frozen,
+		"user":                 r.Header.Get("X-User"),
+	})
+
+	var errFrozen error
+	if frozen {
+		errFrozen = workspaceCache.Freeze()
+	} else {
+		errFrozen = workspaceCache.Unfreeze()
+	}
+	if errFrozen != nil {
+		log.WithError(errFrozen).Error("failed changing frozen state")
+		p.error(w, r, http.StatusInternalServerError, "failed changing frozen state")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if frozen {
+		w.Write([]byte("workspace " + workspace + " frozen\n"))
+	} else {
+		w.Write([]byte("workspace " + workspace + " unfrozen\n"))
+	}
+	log.Info("changed frozen state")
+}
+
+// getWorkspaceCache returns the export cache of the requested workspace, an error is written if it is unknown
+func (p *Proxy) getWorkspaceCache(w http.ResponseWriter, r *http.Request) (workspaceCache *cache.Cache, workspace string, ok bool) {
+	workspace = strings.TrimSpace(strings.ToLower(r.URL.Query().Get("workspace")))
+	if workspace == "" {
+		workspace = cms.WorkspaceLive
+	}
+
+	workspaceCache, ok = p.workspaceCaches[workspace]
+	if !ok {
+		p.error(w, r, http.StatusBadRequest, "workspace worker not found")
+	}
+	return
+}
+
+// nodeLookupError answers failed node lookups in the contentserver export
+func (p *Proxy) nodeLookupError(w http.ResponseWriter, r *http.Request, log logging.Entry, err error) {
+	switch err {
+	case cache.ErrorFileNotExists:
+		p.error(w, r, http.StatusConflict, "cache empty; please try again later")
+	case cache.ErrorNodeNotExists:
+		p.error(w, r, http.StatusNotFound, "node not found")
+	case cache.ErrorIndexNotAvailable:
+		p.error(w, r, http.StatusServiceUnavailable, "contentserver export index not available")
+	default:
+		log.WithError(err).Error("node lookup failed")
+		p.error(w, r, http.StatusInternalServerError, "node lookup failed")
+	}
+}
+
+// writeJSON encodes and compresses a response derived from the current contentserver export, clients have to revalidate it
+func (p *Proxy) writeJSON(w http.ResponseWriter, r *http.Request, log logging.Entry, response interface{}) {
+	encoding := utils.NegotiateEncoding(r.Header.Get("Accept-Encoding"))
+	body, errEncode := json.Marshal(response)
+	if errEncode == nil {
+		body, errEncode = compress(encoding, append(body, '\n'))
+	}
+	if errEncode != nil {
+		log.WithError(errEncode).Error("failed encoding response")
+		p.error(w, r, http.StatusInternalServerError, "failed encoding response")
+		return
+	}
+
+	w.Header().Set("Content-Type", string(mimeApplicationJSON))
+	w.Header().Set("Cache-Control", "no-cache, must-revalidate")
+	w.Header().Set("Vary", "Accept-Encoding")
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Write(body)
+}
+
+// getDocumentParameters extracts id, dimension and workspace of a document request
+func getDocumentParameters(r *http.Request) (id, dimension, workspace string) {
+	id = getRequestParameter(r, "id")
+	dimension = getRequestParameter(r, "dimension")
+	workspace = strings.TrimSpace(strings.ToLower(r.URL.Query().Get("workspace")))
+	if workspace == "" {
+		workspace = cms.WorkspaceLive
+	}
+	return
+}
+
+// parseCacheQuery parses cache listing parameters, olderThan may be a duration or a RFC 3339 timestamp
+func parseCacheQuery(r *http.Request, now time.Time) (query store.Query, err error) {
+	values := r.URL.Query()
+	query = store.Query{
+		Workspace: strings.TrimSpace(strings.ToLower(values.Get("workspace"))),
+		Dimension: values.Get("dimension"),
+		After:     values.Get("cursor"),
+		Limit:     defaultCacheListLimit,
+	}
+
+	if olderThan := values.Get("olderThan"); olderThan != "" {
+		if duration, errDuration := time.ParseDuration(olderThan); errDuration == nil {
+			query.CreatedBefore = now.Add(-duration)
+		} else if timestamp, errTimestamp := time.Parse(time.RFC3339, olderThan); errTimestamp == nil {
+			query.CreatedBefore = timestamp
+		} else {
+			err = errors.New("invalid olderThan: expected duration or RFC 3339 timestamp")
+			return
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > maxCacheListLimit {
+			err = fmt.Errorf("invalid limit: expected 1 to %d", maxCacheListLimit)
+			return
+		}
+	}
+	return
+}
+
+// diffDependencies returns the sorted dependencies only present in upstream (added) or cached (removed)
+func diffDependencies(cached, upstream []string) (added, removed []string) {
+	added, removed = []string{}, []string{}
+	inCached := make(map[string]bool, len(cached))
+	for _, id := range cached {
+		inCached[id] = true
+	}
+	inUpstream := make(map[string]bool, len(upstream))
+	for _, id := range upstream {
+		inUpstream[id] = true
+		if !inCached[id] {
+			added = append(added, id)
+		}
+	}
+	for _, id := range cached {
+		if !inUpstream[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return
+}
+
 func getRequestParameter(r *http.Request, parameter string) string {
 	return getParameter(mux.Vars(r), parameter)
 }
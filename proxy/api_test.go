@@ -1,8 +1,12 @@
 package proxy
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/foomo/neosproxy/cache/content/store"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,3 +24,35 @@ func TestParseAcceptHeader(t *testing.T) {
 	assert.Equal(t, string(mimeApplicationJSON), string(accept))
 
 }
+
+func TestDiffDependencies(t *testing.T) {
+
+	added, removed := diffDependencies([]string{"b", "a", "c"}, []string{"c", "d", "a"})
+	assert.Equal(t, []string{"d"}, added)
+	assert.Equal(t, []string{"b"}, removed)
+
+	added, removed = diffDependencies(nil, []string{"a"})
+	assert.Equal(t, []string{"a"}, added)
+	assert.Empty(t, removed)
+
+}
+
+func TestParseCacheQuery(t *testing.T) {
+
+	now := time.Now()
+
+	query, err := parseCacheQuery(httptest.NewRequest(http.MethodGet, "/cache?workspace=Live&dimension=de&olderThan=24h&limit=10&cursor=abc", nil), now)
+	assert.NoError(t, err)
+	assert.Equal(t, store.Query{Workspace: "live", Dimension: "de", CreatedBefore: now.Add(-24 * time.Hour), After: "abc", Limit: 10}, query)
+
+	query, err = parseCacheQuery(httptest.NewRequest(http.MethodGet, "/cache?olderThan=2020-01-02T15:04:05Z", nil), now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC), query.CreatedBefore)
+	assert.Equal(t, defaultCacheListLimit, query.Limit)
+
+	_, err = parseCacheQuery(httptest.NewRequest(http.MethodGet, "/cache?olderThan=yesterday", nil), now)
+	assert.Error(t, err)
+	_, err = parseCacheQuery(httptest.NewRequest(http.MethodGet, "/cache?limit=100000", nil), now)
+	assert.Error(t, err)
+
+}
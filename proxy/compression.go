@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"bytes"
+
+	"github.com/foomo/neosproxy/utils"
+)
+
+//-----------------------------------------------------------------------------
+// ~ Private methods
+//-----------------------------------------------------------------------------
+
+// compress a response body, an empty encoding returns the body as it is
+func compress(encoding string, body []byte) ([]byte, error) {
+	if encoding == "" {
+		return body, nil
+	}
+	buffer := &bytes.Buffer{}
+	compressor, errCompressor := utils.NewCompressor(encoding, buffer, utils.CompressionFast)
+	if errCompressor != nil {
+		return nil, errCompressor
+	}
+	if _, errWrite := compressor.Write(body); errWrite != nil {
+		return nil, errWrite
+	}
+	if errClose := compressor.Close(); errClose != nil {
+		return nil, errClose
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/foomo/neosproxy/utils"
+)
+
+//-----------------------------------------------------------------------------
+// ~ Constants
+//-----------------------------------------------------------------------------
+
+// headerLegacyEtag is sent by existing consumers instead of If-None-Match
+const headerLegacyEtag = "ETag"
+
+//-----------------------------------------------------------------------------
+// ~ Private methods
+//-----------------------------------------------------------------------------
+
+// quoteEtag returns a strong entity tag
+func quoteEtag(etag string) string {
+	return `"` + etag + `"`
+}
+
+// entityTag returns the strong entity tag of an encoded representation
+func entityTag(etag string, encoding string) string {
+	if encoding == "" {
+		return quoteEtag(etag)
+	}
+	return quoteEtag(etag + "-" + encoding)
+}
+
+// etagMatch uses the weak comparison of If-None-Match against an unquoted etag
+// header is a comma separated list of entity tags or "*"
+func etagMatch(header string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		candidate = strings.Trim(strings.TrimPrefix(candidate, "W/"), `"`)
+		if candidate == etag {
+			return true
+		}
+		// any encoded representation
+		for _, encoding := range utils.Encodings {
+			if candidate == etag+"-"+encoding {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// etagNotModified is true if the request's If-None-Match or legacy ETag header matches etag
+// handled is false if the request does not contain an etag condition
+func etagNotModified(r *http.Request, etag string) (notModified bool, handled bool) {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagMatch(header, etag), true
+	}
+	if header := r.Header.Get(headerLegacyEtag); header != "" {
+		return etagMatch(header, etag), true
+	}
+	return false, false
+}
+
+// modifiedSinceNotModified is true if If-Modified-Since is not before lastModified
+// it must only be evaluated if the request does not contain an etag condition
+func modifiedSinceNotModified(r *http.Request, lastModified time.Time) bool {
+	header := r.Header.Get("If-Modified-Since")
+	if header == "" || lastModified.IsZero() {
+		return false
+	}
+	since, errParse := http.ParseTime(header)
+	if errParse != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// writeNotModified sends a 304 without a body
+func writeNotModified(w http.ResponseWriter, entityTag string, lastModified time.Time) {
+	w.Header().Set("ETag", entityTag)
+	w.Header().Set("Vary", "Accept-Encoding")
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
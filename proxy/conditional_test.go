@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/cache/content/store/memory"
+	"github.com/foomo/neosproxy/client/cms"
+	"github.com/foomo/neosproxy/logging"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	content_cache "github.com/foomo/neosproxy/cache/content"
+)
+
+type testLoader struct{}
+
+func (l testLoader) GetContent(id, dimension, workspace string, ctx context.Context) (cms.Content, error) {
+	return cms.Content{HTML: "<h1>" + id + "</h1>"}, nil
+}
+
+type testObserver struct{}
+
+func (o testObserver) Notify(response content_cache.InvalidationResponse) {}
+
+func TestEtagMatch(t *testing.T) {
+	assert.True(t, etagMatch(`"abc"`, "abc"))
+	assert.True(t, etagMatch(`"xyz", W/"abc"`, "abc"))
+	assert.True(t, etagMatch(`*`, "abc"))
+	assert.True(t, etagMatch(`abc`, "abc"))
+	assert.False(t, etagMatch(`"abcd"`, "abc"))
+	assert.False(t, etagMatch(`"abc"`, ""))
+}
+
+func TestGetContentConditional(t *testing.T) {
+	p := &Proxy{
+		log:             logging.GetDefaultLogEntry(),
+		contentCache:    content_cache.New(0, 0, memory.NewCacheStore(), testLoader{}, testObserver{}, logging.GetDefaultLogEntry()),
+		servedStatsChan: make(chan bool, 100),
+	}
+
+	request := func(method string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/contentserver/export/de/1?workspace=live", nil)
+		r.Header = header
+		r = mux.SetURLVars(r, map[string]string{"dimension": "de", "id": "1"})
+		w := httptest.NewRecorder()
+		p.getContent(w, r)
+		return w
+	}
+
+	// initial request loads the item
+	w := request(http.MethodGet, http.Header{})
+	assert.Equal(t, http.StatusOK, w.Code)
+	item, errGet := p.contentCache.Get("1", "de", "live")
+	assert.NoError(t, errGet)
+	etag := `"` + item.Etag + `"`
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, item.Created.UTC().Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	assert.Contains(t, w.Body.String(), `"html":"\u003ch1\u003e1\u003c/h1\u003e"`)
+
+	// head has headers only
+	w = request(http.MethodHead, http.Header{})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.NotEmpty(t, w.Header().Get("Content-Length"))
+	assert.Empty(t, w.Body.String())
+
+	for name, header := range map[string]http.Header{
+		"if-none-match":      {"If-None-Match": {`"other", ` + etag}},
+		"legacy etag":        {"Etag": {item.Etag}},
+		"if-modified-since":  {"If-Modified-Since": {item.Created.Add(time.Second).UTC().Format(http.TimeFormat)}},
+		"if-none-match head": {"If-None-Match": {"*"}},
+	} {
+		method := http.MethodGet
+		if name == "if-none-match head" {
+			method = http.MethodHead
+		}
+		w = request(method, header)
+		assert.Equal(t, http.StatusNotModified, w.Code, name)
+		assert.Equal(t, etag, w.Header().Get("ETag"), name)
+		// same validators as the full response
+		assert.Equal(t, item.Created.UTC().Format(http.TimeFormat), w.Header().Get("Last-Modified"), name)
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"), name)
+		assert.Empty(t, w.Body.String(), name)
+	}
+
+	// compressed representation
+	w = request(http.MethodGet, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, `"`+item.Etag+`-gzip"`, w.Header().Get("ETag"))
+	reader, errReader := gzip.NewReader(w.Body)
+	assert.NoError(t, errReader)
+	body, errRead := ioutil.ReadAll(reader)
+	assert.NoError(t, errRead)
+	assert.Contains(t, string(body), `"html"`)
+
+	w = request(http.MethodGet, http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {`"` + item.Etag + `-gzip"`}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, `"`+item.Etag+`-gzip"`, w.Header().Get("ETag"))
+
+	// modified
+	for name, header := range map[string]http.Header{
+		"if-none-match":     {"If-None-Match": {`"other"`}},
+		"if-modified-since": {"If-Modified-Since": {item.Created.Add(-time.Hour).UTC().Format(http.TimeFormat)}},
+		// etag conditions take precedence
+		"precedence": {"If-None-Match": {`"other"`}, "If-Modified-Since": {item.Created.Add(time.Hour).UTC().Format(http.TimeFormat)}},
+	} {
+		w = request(http.MethodGet, header)
+		assert.Equal(t, http.StatusOK, w.Code, name)
+		assert.NotEmpty(t, w.Body.String(), name)
+	}
+}
+
+func TestRollbackVersionEtag(t *testing.T) {
+	p := &Proxy{
+		log:             logging.GetDefaultLogEntry(),
+		contentCache:    content_cache.New(0, 2, memory.NewCacheStore(), testLoader{}, testObserver{}, logging.GetDefaultLogEntry()),
+		servedStatsChan: make(chan bool, 100),
+	}
+
+	item, errLoad := p.contentCache.Load("1", "de", "live")
+	assert.NoError(t, errLoad)
+
+	r := httptest.NewRequest(http.MethodPost, "/neosproxy/cache/de/1/versions/1/rollback?workspace=live", nil)
+	r = mux.SetURLVars(r, map[string]string{"dimension": "de", "id": "1", "version": "1"})
+	w := httptest.NewRecorder()
+	p.rollbackVersion(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// the same entity tag as served by getContent
+	assert.Equal(t, `"`+item.GetEtag()+`"`, w.Header().Get("ETag"))
+}
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"context"
 	"net/http"
 	"net/http/httputil"
 	"strings"
@@ -13,13 +14,14 @@ import (
 	"github.com/foomo/neosproxy/logging"
 	"github.com/foomo/neosproxy/model"
 	"github.com/foomo/neosproxy/notifier"
+	"github.com/foomo/neosproxy/scheduler"
 	"github.com/gorilla/mux"
 
 	content_cache "github.com/foomo/neosproxy/cache/content"
 )
 
 // New proxy
-func New(cfg *config.Config, contentLoader cms.ContentLoader, contentStore store.CacheStore, cacheLifetime time.Duration) *Proxy {
+func New(cfg *config.Config, contentLoader cms.Service, contentStore store.CacheStore, cacheLifetime time.Duration) *Proxy {
 
 	reverseProxy := httputil.NewSingleHostReverseProxy(cfg.Neos.URL)
 
@@ -54,11 +56,15 @@ func New(cfg *config.Config, contentLoader cms.ContentLoader, contentStore store
 		req.URL.Path = proxyPath
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	p := &Proxy{
+		ctx:             ctx,
+		cancel:          cancel,
 		log:             logging.GetDefaultLogEntry(),
 		config:          cfg,
 		workspaceCaches: make(map[string]*cache.Cache, len(cfg.Neos.Workspaces)),
 
+		server:       &http.Server{Addr: cfg.Proxy.Address},
 		router:       mux.NewRouter(),
 		proxyHandler: reverseProxy,
 
@@ -70,6 +76,7 @@ func New(cfg *config.Config, contentLoader cms.ContentLoader, contentStore store
 		broker:             notifier.NewBroker(),
 		servedStatsChan:    make(chan bool),
 		servedStatsCounter: uint(0),
+		serving:            make(chan struct{}),
 	}
 
 	go func() {
@@ -85,19 +92,46 @@ func New(cfg *config.Config, contentLoader cms.ContentLoader, contentStore store
 		}
 	}()
 
+	// observers are registered before the first export is loaded
+	p.registerObservers()
+
 	// content cache for html from neos
-	p.contentCache = content_cache.New(cacheLifetime, contentStore, contentLoader, p.broker, p.log)
+	p.contentCache = content_cache.New(cacheLifetime, cfg.Cache.History, contentStore, contentLoader, p.broker, p.log)
 
 	// sitemap / site structure cache for content servers
 	for _, workspace := range cfg.Neos.Workspaces {
-		p.workspaceCaches[workspace] = cache.New(p.broker, workspace, cfg)
+		p.workspaceCaches[workspace] = cache.New(p.broker, contentLoader, workspace, cfg)
+		p.workspaceCaches[workspace].Preload(cfg.Cache.Startup.RetryInterval)
 	}
 
+	// periodic export refreshes and content revalidation
+	p.scheduler = scheduler.New(cfg.Cache.Schedule.QuietWindows, cfg.Cache.Schedule.Jitter)
+	p.scheduleJobs()
+
 	// setup routes
 	p.setupRoutes()
 
-	// append oberservers
+	return p
+}
+
+// registerObservers subscribes the configured notifiers to the workspaces of the broker
+func (p *Proxy) registerObservers() {
+	cfg := p.config
 	for _, observer := range cfg.Observer {
+		if observer.Slack != nil {
+			l := logging.GetDefaultLogEntry().WithField("name", observer.Slack.Name)
+			n := notifier.NewSlackNotifier(observer.Slack.Name, observer.Slack.URL, observer.Slack.Channel)
+			for workspace, subscribers := range cfg.Subscriptions {
+				for _, subscriber := range subscribers {
+					if subscriber == n.GetName() {
+						p.broker.RegisterAlertObserver(workspace, n)
+						l.WithField("workspace", workspace).Debug("alert observer registered at workspace")
+					}
+				}
+			}
+			continue
+		}
+
 		if observer.Webhook == nil {
 			continue
 		}
@@ -117,5 +151,32 @@ func New(cfg *config.Config, contentLoader cms.ContentLoader, contentStore store
 		}
 
 	}
-	return p
+}
+
+// scheduleJobs adds periodic export refreshes of all workspaces and the content revalidation to the scheduler
+func (p *Proxy) scheduleJobs() {
+	cfg := p.config
+	for workspace, workspaceCache := range p.workspaceCaches {
+		spec, ok := cfg.Cache.Schedule.Exports[workspace]
+		if !ok {
+			spec = cfg.Cache.AutoUpdateDuration
+		}
+		if spec == "" {
+			continue
+		}
+		workspaceCache := workspaceCache
+		if err := p.scheduler.Add("export-"+workspace, spec, func() { workspaceCache.Invalidate() }); err != nil {
+			p.log.WithError(err).WithField(logging.FieldWorkspace, workspace).Fatal("failed scheduling contentserver export refresh")
+		}
+	}
+	if spec := cfg.Cache.Schedule.Revalidation; spec != "" {
+		errSchedule := p.scheduler.Add("content-revalidation", spec, func() {
+			if _, err := p.contentCache.Revalidate(); err != nil {
+				p.log.WithError(err).Error("content cache revalidation failed")
+			}
+		})
+		if errSchedule != nil {
+			p.log.WithError(errSchedule).Fatal("failed scheduling content cache revalidation")
+		}
+	}
 }
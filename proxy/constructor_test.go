@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/cache"
+	"github.com/foomo/neosproxy/config"
+	"github.com/foomo/neosproxy/logging"
+	"github.com/foomo/neosproxy/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// refreshes stay pending
+	cfg := &config.Config{}
+	cfg.Cache.Directory = dir
+	cfg.Cache.Debounce = config.Debounce{Wait: time.Hour, MaxWait: time.Hour}
+	cfg.Cache.Schedule.Exports = map[string]string{"live": "1h", "stage": "1h"}
+
+	p := &Proxy{
+		log:             logging.GetDefaultLogEntry(),
+		config:          cfg,
+		workspaceCaches: map[string]*cache.Cache{},
+		scheduler:       scheduler.New(nil, 0),
+	}
+	for _, workspace := range []string{"live", "stage"} {
+		assert.NoError(t, os.MkdirAll(cache.ExportDirectory(dir), 0755))
+		assert.NoError(t, ioutil.WriteFile(cache.ExportFilename(dir, workspace), []byte(`{"id":"root","nodes":{}}`), 0644))
+		p.workspaceCaches[workspace] = cache.New(testBroker{}, nil, workspace, cfg)
+		defer p.workspaceCaches[workspace].Close()
+	}
+	p.scheduleJobs()
+
+	// every job invalidates its own workspace
+	assert.NoError(t, p.scheduler.Run("export-stage"))
+	assert.Equal(t, 0, p.workspaceCaches["live"].Status().PendingRefreshes)
+	assert.Equal(t, 1, p.workspaceCaches["stage"].Status().PendingRefreshes)
+
+	assert.NoError(t, p.scheduler.Run("export-live"))
+	assert.Equal(t, 1, p.workspaceCaches["live"].Status().PendingRefreshes)
+	assert.Equal(t, 1, p.workspaceCaches["stage"].Status().PendingRefreshes)
+}
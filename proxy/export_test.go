@@ -0,0 +1,319 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/foomo/neosproxy/cache"
+	"github.com/foomo/neosproxy/config"
+	"github.com/foomo/neosproxy/logging"
+	"github.com/foomo/neosproxy/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type testBroker struct{}
+
+func (b testBroker) NotifyOnSitemapChange(workspace string) {}
+
+func (b testBroker) NotifyOnExportRejected(workspace string, report model.Report) {}
+
+func TestStreamCachedNeosContentServerExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{}
+	cfg.Cache.Directory = dir
+
+	export := []byte(`{"id":"root","nodes":{}}` + "\n")
+	assert.NoError(t, os.MkdirAll(cache.ExportDirectory(dir), 0755))
+	assert.NoError(t, ioutil.WriteFile(cache.ExportFilename(dir, "live"), export, 0644))
+
+	p := &Proxy{
+		log:             logging.GetDefaultLogEntry(),
+		workspaceCaches: map[string]*cache.Cache{"live": cache.New(testBroker{}, nil, "live", cfg)},
+	}
+	sum := md5.Sum(export)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	request := func(header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/contentserver/export?workspace=live", nil)
+		r.Header = header
+		w := httptest.NewRecorder()
+		p.streamCachedNeosContentServerExport(w, r)
+		return w
+	}
+
+	w := request(http.Header{})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+	assert.Equal(t, string(export), w.Body.String())
+
+	// conditional
+	w = request(http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	w = request(http.Header{"If-Modified-Since": {w.Header().Get("Last-Modified")}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = request(http.Header{"If-None-Match": {`"outdated"`}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// range
+	w = request(http.Header{"Range": {"bytes=0-4"}})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, string(export[:5]), w.Body.String())
+	w = request(http.Header{"Range": {"bytes=5-"}, "If-Range": {etag}})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, string(export[5:]), w.Body.String())
+
+	// pre-compressed
+	w = request(http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"`+hex.EncodeToString(sum[:])+`-gzip"`, w.Header().Get("ETag"))
+	reader, errReader := gzip.NewReader(w.Body)
+	assert.NoError(t, errReader)
+	body, errRead := ioutil.ReadAll(reader)
+	assert.NoError(t, errRead)
+	assert.Equal(t, string(export), string(body))
+}
+
+func TestStreamCachedNeosContentServerExportDimension(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{}
+	cfg.Cache.Directory = dir
+
+	assert.NoError(t, os.MkdirAll(cache.ExportDirectory(dir), 0755))
+	assert.NoError(t, ioutil.WriteFile(cache.ExportFilename(dir, "live"), []byte(`{"de":{"id":"root-de"},"en":{"id":"root-en"}}`), 0644))
+
+	p := &Proxy{
+		log:             logging.GetDefaultLogEntry(),
+		workspaceCaches: map[string]*cache.Cache{"live": cache.New(testBroker{}, nil, "live", cfg)},
+	}
+
+	request := func(query string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/contentserver/export?"+query, nil)
+		r.Header = header
+		w := httptest.NewRecorder()
+		p.streamCachedNeosContentServerExport(w, r)
+		return w
+	}
+
+	full := request("workspace=live", http.Header{})
+	assert.Equal(t, http.StatusOK, full.Code)
+
+	slice := []byte(`{"de":{"id":"root-de"}}`)
+	sum := md5.Sum(slice)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	w := request("workspace=live&dimension=de", http.Header{})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, string(slice), w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.NotEqual(t, full.Header().Get("ETag"), w.Header().Get("ETag"))
+
+	w = request("workspace=live&dimension=de", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = request("workspace=live&dimension=de", http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"`+hex.EncodeToString(sum[:])+`-gzip"`, w.Header().Get("ETag"))
+	reader, errReader := gzip.NewReader(w.Body)
+	assert.NoError(t, errReader)
+	body, errRead := ioutil.ReadAll(reader)
+	assert.NoError(t, errRead)
+	assert.Equal(t, string(slice), string(body))
+
+	w = request("workspace=live&dimension=fr", http.Header{})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetContentServerExportDelta(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{}
+	cfg.Cache.Directory = dir
+	cfg.Cache.ExportHistory = 5
+
+	export := []byte(`{"de":{"id":"root","nodes":{}}}`)
+	assert.NoError(t, os.MkdirAll(cache.ExportDirectory(dir), 0755))
+	assert.NoError(t, ioutil.WriteFile(cache.ExportFilename(dir, "live"), export, 0644))
+	sum := md5.Sum(export)
+	hash := hex.EncodeToString(sum[:])
+
+	p := &Proxy{
+		log:             logging.GetDefaultLogEntry(),
+		workspaceCaches: map[string]*cache.Cache{"live": cache.New(testBroker{}, nil, "live", cfg)},
+	}
+
+	request := func(since string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/contentserver/export/delta?workspace=live&since="+since, nil)
+		w := httptest.NewRecorder()
+		p.getContentServerExportDelta(w, r)
+		return w
+	}
+
+	w := request(hash)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"workspace":"live","from":"`+hash+`","to":"`+hash+`","dimensions":{}}`, w.Body.String())
+
+	assert.Equal(t, http.StatusGone, request("unknown").Code)
+	assert.Equal(t, http.StatusBadRequest, request("").Code)
+}
+
+func TestGetReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{}
+	cfg.Cache.Directory = dir
+	assert.NoError(t, os.MkdirAll(cache.ExportDirectory(dir), 0755))
+	assert.NoError(t, ioutil.WriteFile(cache.ExportFilename(dir, "live"), []byte(`{"id":"root","nodes":{}}`), 0644))
+
+	p := &Proxy{
+		log: logging.GetDefaultLogEntry(),
+		workspaceCaches: map[string]*cache.Cache{
+			"live":  cache.New(testBroker{}, nil, "live", cfg),
+			"stage": cache.New(testBroker{}, nil, "stage", cfg),
+		},
+	}
+
+	w := httptest.NewRecorder()
+	p.getReady(w, httptest.NewRequest(http.MethodGet, "/neosproxy/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"ready":false,"workspaces":{"live":true,"stage":false}}`, w.Body.String())
+	assert.False(t, p.Ready())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, p.WaitForExports(ctx))
+
+	delete(p.workspaceCaches, "stage")
+	w = httptest.NewRecorder()
+	p.getReady(w, httptest.NewRequest(http.MethodGet, "/neosproxy/ready", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, p.Ready())
+	assert.NoError(t, p.WaitForExports(context.Background()))
+}
+
+func TestServeHTTPStartup(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Proxy.BasePath = "/base"
+	p := &Proxy{
+		log:             logging.GetDefaultLogEntry(),
+		config:          cfg,
+		router:          mux.NewRouter(),
+		workspaceCaches: map[string]*cache.Cache{},
+		serving:         make(chan struct{}),
+	}
+	p.router.HandleFunc(neosproxyPath+"/ready", p.getReady)
+	p.router.HandleFunc(neosproxyPath+"/status", func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(path string) int {
+		w := httptest.NewRecorder()
+		p.serveHTTP(w, httptest.NewRequest(http.MethodGet, "/base"+path, nil))
+		return w.Code
+	}
+
+	// only readiness is answered during startup
+	assert.Equal(t, http.StatusOK, request(neosproxyPath+"/ready"))
+	assert.Equal(t, http.StatusServiceUnavailable, request(neosproxyPath+"/status"))
+
+	close(p.serving)
+	assert.Equal(t, http.StatusOK, request(neosproxyPath+"/status"))
+}
+
+func TestGetNodeAndResolveURI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{}
+	cfg.Cache.Directory = dir
+
+	p := &Proxy{
+		log:             logging.GetDefaultLogEntry(),
+		workspaceCaches: map[string]*cache.Cache{"live": cache.New(testBroker{}, nil, "live", cfg)},
+	}
+
+	getNode := func(dimension, id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/contentserver/export/node/"+dimension+"/"+id+"?workspace=live", nil)
+		r = mux.SetURLVars(r, map[string]string{"dimension": dimension, "id": id})
+		w := httptest.NewRecorder()
+		p.getNode(w, r)
+		return w
+	}
+	resolve := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		p.resolveURI(w, httptest.NewRequest(http.MethodGet, "/contentserver/export/resolve?"+query, nil))
+		return w
+	}
+	gunzip := func(w *httptest.ResponseRecorder) string {
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		reader, errReader := gzip.NewReader(w.Body)
+		assert.NoError(t, errReader)
+		body, errRead := ioutil.ReadAll(reader)
+		assert.NoError(t, errRead)
+		return string(body)
+	}
+
+	// no export yet
+	assert.Equal(t, http.StatusConflict, getNode("de", "products").Code)
+
+	export := []byte(`{"de":{"id":"root","URI":"/de","index":["products"],"nodes":{"products":{"id":"products","URI":"/de/products","name":"Produkte","nodes":{}}}}}`)
+	assert.NoError(t, os.MkdirAll(cache.ExportDirectory(dir), 0755))
+	assert.NoError(t, ioutil.WriteFile(cache.ExportFilename(dir, "live"), export, 0644))
+	p.workspaceCaches["live"] = cache.New(testBroker{}, nil, "live", cfg)
+	sum := md5.Sum(export)
+
+	w := getNode("de", "products")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"workspace": "live",
+		"hash": "`+hex.EncodeToString(sum[:])+`",
+		"dimension": "de",
+		"node": {"id": "products", "parentId": "root", "uri": "/de/products", "name": "Produkte"},
+		"parents": [{"id": "root", "uri": "/de"}],
+		"children": []
+	}`, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, getNode("de", "unknown").Code)
+
+	w = resolve("uri=/de/products/&workspace=live")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"workspace": "live", "hash": "`+hex.EncodeToString(sum[:])+`", "dimension": "de", "id": "products", "uri": "/de/products", "name": "Produkte"}`, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, resolve("uri=/de/products&dimension=en").Code)
+
+	// compressed like all other responses
+	r := httptest.NewRequest(http.MethodGet, "/contentserver/export/resolve?uri=/de/products&workspace=live", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	p.resolveURI(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"workspace": "live", "hash": "`+hex.EncodeToString(sum[:])+`", "dimension": "de", "id": "products", "uri": "/de/products", "name": "Produkte"}`, gunzip(w))
+
+	r = httptest.NewRequest(http.MethodGet, "/contentserver/export/node/de/products?workspace=live", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r = mux.SetURLVars(r, map[string]string{"dimension": "de", "id": "products"})
+	w = httptest.NewRecorder()
+	p.getNode(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, gunzip(w), `"parents":[{"id":"root","uri":"/de"}]`)
+
+	assert.Equal(t, http.StatusBadRequest, resolve("workspace=live").Code)
+	assert.Equal(t, http.StatusBadRequest, resolve("uri=/de&workspace=unknown").Code)
+}
@@ -1,9 +1,12 @@
 package proxy
 
 import (
+	"context"
 	"crypto/subtle"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	jwtmiddleware "github.com/auth0/go-jwt-middleware"
 	"github.com/foomo/neosproxy/logging"
@@ -16,16 +19,113 @@ import (
 // Run a proxy
 func (p *Proxy) Run() error {
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// strip prefix
-		r.URL.Path = strings.TrimPrefix(r.URL.Path, p.config.Proxy.BasePath)
-		r.RequestURI = strings.TrimPrefix(r.RequestURI, p.config.Proxy.BasePath)
+	http.HandleFunc("/", p.serveHTTP)
+
+	// listen right away, readiness probes are answered while waiting for exports
+	addr := p.server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	listener, errListen := net.Listen("tcp", addr)
+	if errListen != nil {
+		return errListen
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- p.server.Serve(listener)
+	}()
+
+	if p.config.Cache.Startup.WaitForExports {
+		p.waitForExports()
+	}
+	close(p.serving)
+
+	p.scheduler.Start()
+	return <-served
+}
 
-		// default router
-		p.router.ServeHTTP(w, r)
-	})
+// Ready is true if every workspace has a valid contentserver export
+func (p *Proxy) Ready() bool {
+	for _, workspaceCache := range p.workspaceCaches {
+		if !workspaceCache.IsReady() {
+			return false
+		}
+	}
+	return true
+}
+
+// WaitForExports blocks until every workspace has a valid contentserver export
+func (p *Proxy) WaitForExports(ctx context.Context) error {
+	for _, workspaceCache := range p.workspaceCaches {
+		select {
+		case <-workspaceCache.Ready():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Shutdown stops scheduled jobs and running export downloads and gracefully shuts down the server
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.cancel()
+	p.scheduler.Stop()
+	for _, workspaceCache := range p.workspaceCaches {
+		workspaceCache.Close()
+	}
+	return p.server.Shutdown(ctx)
+}
+
+//-----------------------------------------------------------------------------
+// ~ Private methods
+//-----------------------------------------------------------------------------
 
-	return http.ListenAndServe(p.config.Proxy.Address, nil)
+// serveHTTP strips the base path and routes a request, only readiness is answered during startup
+func (p *Proxy) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// strip prefix
+	r.URL.Path = strings.TrimPrefix(r.URL.Path, p.config.Proxy.BasePath)
+	r.RequestURI = strings.TrimPrefix(r.RequestURI, p.config.Proxy.BasePath)
+
+	select {
+	case <-p.serving:
+	default:
+		if r.URL.Path != neosproxyPath+"/ready" {
+			p.serviceNotAvailable(w, r)
+			return
+		}
+	}
+
+	// default router
+	p.router.ServeHTTP(w, r)
+}
+
+// waitForExports delays serving until every workspace has a valid export or the configured timeout is reached
+func (p *Proxy) waitForExports() {
+
+	start := time.Now()
+	p.log.Info("waiting for contentserver exports of all workspaces")
+
+	ctx := p.ctx
+	if timeout := p.config.Cache.Startup.WaitTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := p.WaitForExports(ctx); err != nil {
+		if p.ctx.Err() != nil {
+			return
+		}
+		pending := []string{}
+		for workspace, workspaceCache := range p.workspaceCaches {
+			if !workspaceCache.IsReady() {
+				pending = append(pending, workspace)
+			}
+		}
+		p.log.WithDuration(start).WithError(err).WithField("workspaces", pending).Warn("serving without contentserver exports of all workspaces")
+		return
+	}
+	p.log.WithDuration(start).Info("contentserver exports of all workspaces loaded")
 }
 
 //-----------------------------------------------------------------------------
@@ -17,10 +17,19 @@ func (p *Proxy) setupRoutes() {
 
 	// hijack content server export routes
 
-	// content tree / sitemap
+	// content tree / sitemap => /contentserver/export?workspace=stage&dimension=de
 	p.router.HandleFunc(routeContentServerExport, p.streamCachedNeosContentServerExport)
 	p.router.HandleFunc(routeContentServerExport, p.streamCachedNeosContentServerExport).Queries("workspace", "{workspace}")
 
+	// delta => /contentserver/export/delta?workspace=stage&since=<hash>
+	p.router.HandleFunc(routeContentServerExport+"/delta", p.getContentServerExportDelta).Methods(http.MethodGet)
+
+	// node lookup => /contentserver/export/node/de/571fd1ae-c8e4-4d91-a708-d97025fb015c?workspace=stage
+	p.router.HandleFunc(routeContentServerExport+"/node/{dimension}/{id}", p.getNode).Methods(http.MethodGet)
+
+	// uri resolution => /contentserver/export/resolve?uri=/de/products&workspace=stage
+	p.router.HandleFunc(routeContentServerExport+"/resolve", p.resolveURI).Methods(http.MethodGet)
+
 	// etag
 	p.router.HandleFunc(routeContentServerExport+"/etag/{dimension}/{id}", p.getEtagByID).Methods(http.MethodGet)
 	p.router.HandleFunc(routeContentServerExport+"/etag/{dimension}/{id}", p.getEtagByID).Methods(http.MethodGet).Queries("workspace", "{workspace}")
@@ -33,8 +42,11 @@ func (p *Proxy) setupRoutes() {
 	p.router.HandleFunc(routeContentServerExport+"/{dimension}/{id}", p.getContent).Methods(http.MethodGet)
 	p.router.HandleFunc(routeContentServerExport+"/{dimension}/{id}", p.getContent).Methods(http.MethodGet).Queries("workspace", "{workspace}")
 
-	p.router.HandleFunc(routeContentServerExport+"/{dimension}/{id}", p.getEtagByID).Methods(http.MethodHead)
-	p.router.HandleFunc(routeContentServerExport+"/{dimension}/{id}", p.getEtagByID).Methods(http.MethodHead).Queries("workspace", "{workspace}")
+	p.router.HandleFunc(routeContentServerExport+"/{dimension}/{id}", p.getContent).Methods(http.MethodHead)
+	p.router.HandleFunc(routeContentServerExport+"/{dimension}/{id}", p.getContent).Methods(http.MethodHead).Queries("workspace", "{workspace}")
+
+	// readiness => 503 until every workspace has a valid contentserver export, no auth for probes
+	p.router.HandleFunc(neosproxyPath+"/ready", p.getReady).Methods(http.MethodGet)
 
 	// api
 	// neosproxy/cache/%s?workspace=%s
@@ -43,6 +55,16 @@ func (p *Proxy) setupRoutes() {
 	neosproxyRouter.HandleFunc("/cache/all", p.invalidateCacheAll).Methods(http.MethodDelete)
 	neosproxyRouter.HandleFunc("/cache/{id}", p.invalidateCache).Methods(http.MethodDelete)
 	neosproxyRouter.HandleFunc("/cache/{id}", p.invalidateCache).Methods(http.MethodDelete).Queries("workspace", "{workspace}").Name("api-delete-cache")
+	neosproxyRouter.HandleFunc("/cache", p.listCache).Methods(http.MethodGet)
+	neosproxyRouter.HandleFunc("/cache/{dimension}/{id}", p.getCacheItem).Methods(http.MethodGet)
+	neosproxyRouter.HandleFunc("/cache/{dimension}/{id}/diff", p.diffUpstream).Methods(http.MethodGet)
+	neosproxyRouter.HandleFunc("/cache/{dimension}/{id}/versions", p.getVersions).Methods(http.MethodGet)
+	neosproxyRouter.HandleFunc("/cache/{dimension}/{id}/versions/diff", p.diffVersions).Methods(http.MethodGet)
+	neosproxyRouter.HandleFunc("/cache/{dimension}/{id}/versions/{version}/rollback", p.rollbackVersion).Methods(http.MethodPost)
+	neosproxyRouter.HandleFunc("/export/history", p.getExportHistory).Methods(http.MethodGet)
+	neosproxyRouter.HandleFunc("/export/rollback/{hash}", p.rollbackExport).Methods(http.MethodPost)
+	neosproxyRouter.HandleFunc("/export/freeze", p.freezeExport).Methods(http.MethodPost)
+	neosproxyRouter.HandleFunc("/export/unfreeze", p.unfreezeExport).Methods(http.MethodPost)
 	neosproxyRouter.HandleFunc("/status", p.streamStatus).Methods(http.MethodGet)
 
 	// error handling
@@ -1,13 +1,18 @@
 package proxy
 
 import (
+	"context"
+	"net/http"
 	"net/http/httputil"
+	"time"
 
 	"github.com/foomo/neosproxy/cache"
+	"github.com/foomo/neosproxy/cache/export"
 	"github.com/foomo/neosproxy/config"
 	"github.com/foomo/neosproxy/logging"
 	"github.com/foomo/neosproxy/model"
 	"github.com/foomo/neosproxy/notifier"
+	"github.com/foomo/neosproxy/scheduler"
 	"github.com/gorilla/mux"
 
 	content_cache "github.com/foomo/neosproxy/cache/content"
@@ -15,17 +20,22 @@ import (
 
 // Proxy struct definition
 type Proxy struct {
+	ctx             context.Context // cancelled on Shutdown
+	cancel          context.CancelFunc
 	log             logging.Entry
 	basicAuth       []basicAuth
 	config          *config.Config
 	workspaceCaches map[string]*cache.Cache
 
+	server       *http.Server
 	router       *mux.Router
 	proxyHandler *httputil.ReverseProxy
 	contentCache *content_cache.Cache
 
-	status *model.Status
-	broker *notifier.Broker
+	status    *model.Status
+	broker    *notifier.Broker
+	scheduler *scheduler.Scheduler
+	serving   chan struct{} // closed once startup is done, until then only readiness is answered
 
 	servedStatsChan    chan bool
 	servedStatsCounter uint // served requests per minute
@@ -35,3 +45,65 @@ type basicAuth struct {
 	user     string
 	password string
 }
+
+// cacheListResponse is a page of cached items
+type cacheListResponse struct {
+	Items  []cacheItemSummary `json:"items"`
+	Cursor string             `json:"cursor,omitempty"` // cursor of the next page, empty on the last page
+}
+
+// cacheItemSummary of a listed cache item
+type cacheItemSummary struct {
+	Hash            string    `json:"hash"`
+	ID              string    `json:"id"`
+	Dimension       string    `json:"dimension"`
+	Workspace       string    `json:"workspace"`
+	Etag            string    `json:"etag"`
+	Size            int       `json:"size"`
+	Created         time.Time `json:"created"`
+	ValidUntil      time.Time `json:"validUntil"`
+	DependencyCount int       `json:"dependencyCount"`
+}
+
+// cacheItemDetails of a cached item
+type cacheItemDetails struct {
+	Hash          string    `json:"hash"`
+	ID            string    `json:"id"`
+	Dimension     string    `json:"dimension"`
+	Workspace     string    `json:"workspace"`
+	Etag          string    `json:"etag"`
+	Size          int       `json:"size"`
+	Created       time.Time `json:"created"`
+	ValidUntil    time.Time `json:"validUntil"`
+	Dependencies  []string  `json:"dependencies"`
+	Versions      int       `json:"versions"`                // versions in history
+	PinnedVersion int       `json:"pinnedVersion,omitempty"` // version rolled back to
+}
+
+// nodeResponse of a node lookup in the contentserver export
+type nodeResponse struct {
+	Workspace string `json:"workspace"`
+	Hash      string `json:"hash"` // of the contentserver export
+	export.NodeDetails
+}
+
+// resolveResponse of an uri resolution in the contentserver export
+type resolveResponse struct {
+	Workspace string `json:"workspace"`
+	Hash      string `json:"hash"` // of the contentserver export
+	Dimension string `json:"dimension"`
+	export.NodeSummary
+}
+
+// readyResponse reports whether every workspace has a valid contentserver export
+type readyResponse struct {
+	Ready      bool            `json:"ready"`
+	Workspaces map[string]bool `json:"workspaces"`
+}
+
+// exportHistoryResponse lists retained contentserver exports of a workspace
+type exportHistoryResponse struct {
+	Workspace string                `json:"workspace"`
+	Status    model.ExportStatus    `json:"status"`
+	Versions  []cache.ExportVersion `json:"versions"`
+}
@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// QuietWindow is a daily time range without any job runs, End may be before Start to span midnight
+type QuietWindow struct {
+	Start time.Duration // since midnight
+	End   time.Duration // since midnight
+}
+
+//------------------------------------------------------------------
+// ~ CONSTANTS / VARS
+//------------------------------------------------------------------
+
+// ErrorInvalidQuietWindow error in case a quiet window can not be parsed
+var ErrorInvalidQuietWindow = errors.New("invalid quiet window, expected hh:mm-hh:mm")
+
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+// Parse a schedule spec: a duration like "30m" or a cron expression like "*/15 * * * *" or "@daily"
+func Parse(spec string) (cron.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if interval, errDuration := time.ParseDuration(spec); errDuration == nil {
+		if interval <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be positive", spec)
+		}
+		return cron.Every(interval), nil
+	}
+	schedule, errParse := cronParser.Parse(spec)
+	if errParse != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %s", spec, errParse.Error())
+	}
+	return schedule, nil
+}
+
+// ParseQuietWindow parses a daily time range like "22:00-06:00"
+func ParseQuietWindow(value string) (window QuietWindow, err error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 2 {
+		err = ErrorInvalidQuietWindow
+		return
+	}
+
+	offsets := make([]time.Duration, 2)
+	for i, part := range parts {
+		clock, errParse := time.Parse("15:04", strings.TrimSpace(part))
+		if errParse != nil {
+			err = ErrorInvalidQuietWindow
+			return
+		}
+		offsets[i] = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+	}
+	if offsets[0] == offsets[1] {
+		err = ErrorInvalidQuietWindow
+		return
+	}
+
+	window = QuietWindow{Start: offsets[0], End: offsets[1]}
+	return
+}
+
+// Contains is true if t is within the window
+func (w QuietWindow) Contains(t time.Time) bool {
+	offset := t.Sub(midnight(t))
+	if w.Start < w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// EndAfter returns the end of the window containing t
+func (w QuietWindow) EndAfter(t time.Time) time.Time {
+	day := midnight(t)
+	if w.Start > w.End && t.Sub(day) >= w.Start {
+		day = day.AddDate(0, 0, 1)
+	}
+	return clock(day, w.End)
+}
+
+func (w QuietWindow) String() string {
+	return formatOffset(w.Start) + "-" + formatOffset(w.End)
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// clock returns the time of day, robust against daylight saving time changes
+func clock(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+func formatOffset(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset/time.Hour), int(offset%time.Hour/time.Minute))
+}
@@ -0,0 +1,234 @@
+// Package scheduler runs periodic jobs like export refreshes and content revalidation sweeps
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/foomo/neosproxy/logging"
+	"github.com/foomo/neosproxy/model"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+//------------------------------------------------------------------
+// ~ TYPES
+//------------------------------------------------------------------
+
+// Scheduler runs jobs on their schedules, runs within quiet windows are postponed to the end of the window
+type Scheduler struct {
+	lock         sync.RWMutex
+	jobs         []*job
+	quietWindows []QuietWindow
+	jitter       time.Duration
+
+	randomLock sync.Mutex
+	random     *rand.Rand
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	now      func() time.Time
+	newTimer func(d time.Duration) (c <-chan time.Time, stop func() bool)
+}
+
+type job struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	run      func()
+
+	next         time.Time
+	last         time.Time
+	lastDuration time.Duration
+}
+
+//------------------------------------------------------------------
+// ~ CONSTANTS / VARS
+//------------------------------------------------------------------
+
+// maxQuietWindowSkips prevents endless loops of overlapping quiet windows
+const maxQuietWindowSkips = 10
+
+// ErrorJobNotExists error in case a job has not been added
+var ErrorJobNotExists = errors.New("scheduled job does not exist")
+
+//------------------------------------------------------------------
+// ~ CONSTRUCTOR
+//------------------------------------------------------------------
+
+// New creates a scheduler, every run is delayed by a random duration up to jitter to spread replicas
+func New(quietWindows []QuietWindow, jitter time.Duration) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		jobs:         []*job{},
+		quietWindows: quietWindows,
+		jitter:       jitter,
+		random:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		ctx:          ctx,
+		cancel:       cancel,
+		now:          time.Now,
+		newTimer:     newTimer,
+	}
+}
+
+//------------------------------------------------------------------
+// ~ PUBLIC METHODS
+//------------------------------------------------------------------
+
+// Add a job, spec is a duration or a cron expression, see Parse
+// jobs have to be added before Start
+func (s *Scheduler) Add(name string, spec string, run func()) error {
+	schedule, errParse := Parse(spec)
+	if errParse != nil {
+		return errParse
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.jobs = append(s.jobs, &job{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		run:      run,
+	})
+	return nil
+}
+
+// Start running all jobs
+func (s *Scheduler) Start() {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, j := range s.jobs {
+		go s.runJob(j)
+	}
+}
+
+// Stop all jobs, running jobs are not interrupted
+func (s *Scheduler) Stop() {
+	s.cancel()
+}
+
+// Run a job immediately, its schedule is not changed
+func (s *Scheduler) Run(name string) error {
+	s.lock.RLock()
+	var found *job
+	for _, j := range s.jobs {
+		if j.name == name {
+			found = j
+			break
+		}
+	}
+	s.lock.RUnlock()
+
+	if found == nil {
+		return ErrorJobNotExists
+	}
+	s.run(found)
+	return nil
+}
+
+// Status of all jobs with their next run
+func (s *Scheduler) Status() []model.ScheduledJobStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	status := make([]model.ScheduledJobStatus, len(s.jobs))
+	for i, j := range s.jobs {
+		status[i] = model.ScheduledJobStatus{
+			Name:     j.name,
+			Schedule: j.spec,
+			Next:     j.next,
+			Last:     j.last,
+		}
+		if !j.last.IsZero() {
+			status[i].LastDuration = j.lastDuration.String()
+		}
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+	return status
+}
+
+//------------------------------------------------------------------
+// ~ PRIVATE METHODS
+//------------------------------------------------------------------
+
+func (s *Scheduler) runJob(j *job) {
+
+	// logger
+	log := logging.GetDefaultLogEntry().WithField("job", j.name)
+
+	for {
+		now := s.now()
+		next := s.nextRun(j.schedule, now)
+
+		s.lock.Lock()
+		j.next = next
+		s.lock.Unlock()
+
+		log.WithField("next", next).Debug("scheduled job")
+
+		timer, stop := s.newTimer(next.Sub(now))
+		select {
+		case <-s.ctx.Done():
+			stop()
+			return
+		case <-timer:
+		}
+
+		s.run(j)
+	}
+}
+
+// run a job and record the run
+func (s *Scheduler) run(j *job) {
+	start := s.now()
+	j.run()
+
+	s.lock.Lock()
+	j.last = start
+	j.lastDuration = s.now().Sub(start)
+	s.lock.Unlock()
+
+	logging.GetDefaultLogEntry().WithDuration(start).WithFields(logrus.Fields{
+		"job":      j.name,
+		"schedule": j.spec,
+	}).Info("ran scheduled job")
+}
+
+// nextRun returns the next run after from, delayed by jitter and postponed by quiet windows
+// the jittered run is checked against the quiet windows, a run never starts inside one
+func (s *Scheduler) nextRun(schedule cron.Schedule, from time.Time) time.Time {
+	next := schedule.Next(from)
+	if s.jitter > 0 {
+		s.randomLock.Lock()
+		next = next.Add(time.Duration(s.random.Int63n(int64(s.jitter))))
+		s.randomLock.Unlock()
+	}
+
+	for i := 0; i < maxQuietWindowSkips; i++ {
+		window, ok := s.quietWindow(next)
+		if !ok {
+			break
+		}
+		next = window.EndAfter(next)
+	}
+	return next
+}
+
+func newTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+func (s *Scheduler) quietWindow(t time.Time) (QuietWindow, bool) {
+	for _, window := range s.quietWindows {
+		if window.Contains(t) {
+			return window, true
+		}
+	}
+	return QuietWindow{}, false
+}
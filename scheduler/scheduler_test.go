@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for spec, valid := range map[string]bool{
+		"30m":          true,
+		"*/15 * * * *": true,
+		"0 3 * * 1-5":  true,
+		"@daily":       true,
+		"@every 1h":    true,
+		"0s":           false,
+		"-5m":          false,
+		"* * *":        false,
+		"every hour":   false,
+	} {
+		_, err := Parse(spec)
+		assert.Equal(t, valid, err == nil, spec)
+	}
+
+	schedule, err := Parse("*/15 * * * *")
+	assert.NoError(t, err)
+	from := time.Date(2019, 10, 1, 12, 7, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2019, 10, 1, 12, 15, 0, 0, time.UTC), schedule.Next(from))
+}
+
+func TestQuietWindow(t *testing.T) {
+	day := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	window, err := ParseQuietWindow("02:00-03:30")
+	assert.NoError(t, err)
+	assert.Equal(t, "02:00-03:30", window.String())
+	assert.False(t, window.Contains(at(1, 59)))
+	assert.True(t, window.Contains(at(2, 0)))
+	assert.True(t, window.Contains(at(3, 29)))
+	assert.False(t, window.Contains(at(3, 30)))
+	assert.Equal(t, at(3, 30), window.EndAfter(at(2, 15)))
+
+	// spanning midnight
+	window, err = ParseQuietWindow("22:00-06:00")
+	assert.NoError(t, err)
+	assert.True(t, window.Contains(at(23, 0)))
+	assert.True(t, window.Contains(at(5, 0)))
+	assert.False(t, window.Contains(at(12, 0)))
+	assert.Equal(t, at(30, 0), window.EndAfter(at(23, 0)))
+	assert.Equal(t, at(6, 0), window.EndAfter(at(1, 0)))
+
+	for _, value := range []string{"", "02:00", "02:00-02:00", "25:00-03:00", "2-3"} {
+		_, err = ParseQuietWindow(value)
+		assert.Equal(t, ErrorInvalidQuietWindow, err, value)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	window, _ := ParseQuietWindow("22:00-06:00")
+	s := New([]QuietWindow{window}, 0)
+	schedule, _ := Parse("0 * * * *")
+
+	from := time.Date(2019, 10, 1, 20, 30, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2019, 10, 1, 21, 0, 0, 0, time.Local), s.nextRun(schedule, from))
+
+	// postponed to the end of the quiet window
+	from = time.Date(2019, 10, 1, 21, 30, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2019, 10, 2, 6, 0, 0, 0, time.Local), s.nextRun(schedule, from))
+
+	// jitter
+	s = New(nil, time.Minute)
+	from = time.Date(2019, 10, 1, 20, 30, 0, 0, time.Local)
+	for i := 0; i < 100; i++ {
+		next := s.nextRun(schedule, from)
+		assert.False(t, next.Before(time.Date(2019, 10, 1, 21, 0, 0, 0, time.Local)))
+		assert.True(t, next.Before(time.Date(2019, 10, 1, 21, 1, 0, 0, time.Local)))
+	}
+
+	// jitter exceeding the distance to a quiet window does not push a run into it
+	window, _ = ParseQuietWindow("02:00-03:00")
+	s = New([]QuietWindow{window}, 10*time.Minute)
+	s.random = rand.New(rand.NewSource(1))
+	schedule, _ = Parse("55 1 * * *")
+	from = time.Date(2019, 10, 1, 1, 0, 0, 0, time.Local)
+	end := time.Date(2019, 10, 1, 3, 0, 0, 0, time.Local)
+	postponed := 0
+	for i := 0; i < 100; i++ {
+		next := s.nextRun(schedule, from)
+		assert.False(t, window.Contains(next), next)
+		if next.Equal(end) {
+			postponed++
+			continue
+		}
+		assert.False(t, next.Before(time.Date(2019, 10, 1, 1, 55, 0, 0, time.Local)), next)
+		assert.True(t, next.Before(time.Date(2019, 10, 1, 2, 0, 0, 0, time.Local)), next)
+	}
+	assert.True(t, postponed > 0 && postponed < 100, "postponed %d", postponed)
+}
+
+type testTimer struct {
+	d time.Duration
+	c chan time.Time
+}
+
+// testClock is advanced by firing the timers of the scheduler
+type testClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers chan testTimer
+}
+
+func (c *testClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *testClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := testTimer{d: d, c: make(chan time.Time, 1)}
+	c.timers <- timer
+	return timer.c, func() bool { return true }
+}
+
+// next waits for the scheduler to wait for its next run
+func (c *testClock) next(t *testing.T) testTimer {
+	select {
+	case timer := <-c.timers:
+		return timer
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not wait for a run")
+		return testTimer{}
+	}
+}
+
+// fire advances the clock by the duration of the timer and fires it
+func (c *testClock) fire(timer testTimer) {
+	c.lock.Lock()
+	c.now = c.now.Add(timer.d)
+	now := c.now
+	c.lock.Unlock()
+	timer.c <- now
+}
+
+func TestScheduler(t *testing.T) {
+	clock := &testClock{
+		now:    time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC),
+		timers: make(chan testTimer, 1),
+	}
+	s := New(nil, 0)
+	s.now = clock.Now
+	s.newTimer = clock.NewTimer
+
+	runs := int32(0)
+	assert.NoError(t, s.Add("test", "1s", func() { atomic.AddInt32(&runs, 1) }))
+	assert.Error(t, s.Add("invalid", "invalid", func() {}))
+
+	s.Start()
+	defer s.Stop()
+
+	timer := clock.next(t)
+	assert.Equal(t, time.Second, timer.d)
+	status := s.Status()
+	assert.Len(t, status, 1)
+	assert.Equal(t, "test", status[0].Name)
+	assert.Equal(t, "1s", status[0].Schedule)
+	assert.Equal(t, time.Date(2019, 10, 1, 12, 0, 1, 0, time.UTC), status[0].Next)
+	assert.True(t, status[0].Last.IsZero())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&runs))
+
+	// the run is recorded before waiting for the next one
+	clock.fire(timer)
+	timer = clock.next(t)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+	status = s.Status()
+	assert.Equal(t, time.Date(2019, 10, 1, 12, 0, 1, 0, time.UTC), status[0].Last)
+	assert.Equal(t, time.Date(2019, 10, 1, 12, 0, 2, 0, time.UTC), status[0].Next)
+
+	// manual runs keep the schedule
+	assert.NoError(t, s.Run("test"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&runs))
+	assert.Equal(t, time.Date(2019, 10, 1, 12, 0, 2, 0, time.UTC), s.Status()[0].Next)
+	assert.Equal(t, ErrorJobNotExists, s.Run("invalid"))
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// supported content encodings
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+)
+
+// CompressionLevel trades speed for size
+type CompressionLevel int
+
+const (
+	// CompressionFast for compressing responses on the fly
+	CompressionFast CompressionLevel = iota
+	// CompressionBest for compressing once and serving often
+	CompressionBest
+)
+
+// Encodings in order of preference
+var Encodings = []string{EncodingBrotli, EncodingGzip}
+
+// ErrorUnsupportedEncoding error in case of an unknown content encoding
+var ErrorUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// NewCompressor returns a writer compressing to w, it must be closed to flush all data
+func NewCompressor(encoding string, w io.Writer, level CompressionLevel) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingGzip:
+		if level == CompressionBest {
+			return gzip.NewWriterLevel(w, gzip.BestCompression)
+		}
+		return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	case EncodingBrotli:
+		if level == CompressionBest {
+			return brotli.NewWriterLevel(w, 9), nil
+		}
+		return brotli.NewWriterLevel(w, 4), nil
+	}
+	return nil, ErrorUnsupportedEncoding
+}
+
+// NegotiateEncoding picks the preferred supported encoding of an Accept-Encoding header
+// an empty string means identity
+func NegotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		values := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(values[0]))
+		quality := 1.0
+		for _, param := range values[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, errParse := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); errParse == nil {
+					quality = q
+				}
+			}
+		}
+		qualities[coding] = quality
+	}
+
+	best := ""
+	bestQuality := 0.0
+	for _, encoding := range Encodings {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > bestQuality {
+			best = encoding
+			bestQuality = quality
+		}
+	}
+	return best
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "", NegotiateEncoding(""))
+	assert.Equal(t, "", NegotiateEncoding("identity"))
+	assert.Equal(t, EncodingGzip, NegotiateEncoding("gzip"))
+	assert.Equal(t, EncodingBrotli, NegotiateEncoding("gzip, deflate, br"))
+	assert.Equal(t, EncodingGzip, NegotiateEncoding("br;q=0.5, gzip;q=0.8"))
+	assert.Equal(t, EncodingGzip, NegotiateEncoding("br;q=0, *"))
+	assert.Equal(t, "", NegotiateEncoding("gzip;q=0"))
+}
+
+func TestNewCompressor(t *testing.T) {
+	data := strings.Repeat("<h1>compress me</h1>", 100)
+	for _, encoding := range Encodings {
+		for _, level := range []CompressionLevel{CompressionFast, CompressionBest} {
+			buffer := &bytes.Buffer{}
+			compressor, err := NewCompressor(encoding, buffer, level)
+			assert.NoError(t, err)
+			_, err = compressor.Write([]byte(data))
+			assert.NoError(t, err)
+			assert.NoError(t, compressor.Close())
+			assert.True(t, buffer.Len() < len(data))
+
+			var decompressed []byte
+			if encoding == EncodingGzip {
+				reader, errReader := gzip.NewReader(buffer)
+				assert.NoError(t, errReader)
+				decompressed, err = ioutil.ReadAll(reader)
+			} else {
+				decompressed, err = ioutil.ReadAll(brotli.NewReader(buffer))
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, data, string(decompressed))
+		}
+	}
+
+	_, err := NewCompressor("deflate", &bytes.Buffer{}, CompressionFast)
+	assert.Equal(t, ErrorUnsupportedEncoding, err)
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffContextLines number of unchanged lines around a change in a unified diff
+const DiffContextLines = 3
+
+type diffOp byte
+
+const (
+	diffEqual  diffOp = ' '
+	diffDelete diffOp = '-'
+	diffInsert diffOp = '+'
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// UnifiedDiff returns a line based unified diff of two texts, empty if they are equal
+func UnifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+
+	lines := diffLines(splitLines(from), splitLines(to))
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "--- %s\n+++ %s\n", fromName, toName)
+
+	// positions of the current line in from and to (0 based)
+	fromPos, toPos := 0, 0
+	for i := 0; i < len(lines); {
+		if lines[i].op == diffEqual {
+			fromPos++
+			toPos++
+			i++
+			continue
+		}
+
+		// hunk start including leading context
+		start := i - DiffContextLines
+		if start < 0 {
+			start = 0
+		}
+		hunkFrom := fromPos - (i - start)
+		hunkTo := toPos - (i - start)
+
+		// hunk end: stop at a gap of unchanged lines wider than the context on both sides
+		end := i
+		for end < len(lines) {
+			if lines[end].op != diffEqual {
+				end++
+				continue
+			}
+			gap := end
+			for gap < len(lines) && lines[gap].op == diffEqual {
+				gap++
+			}
+			if gap == len(lines) || gap-end > 2*DiffContextLines {
+				end += min(gap-end, DiffContextLines)
+				break
+			}
+			end = gap
+		}
+
+		fromCount, toCount := 0, 0
+		for _, line := range lines[start:end] {
+			if line.op != diffInsert {
+				fromCount++
+			}
+			if line.op != diffDelete {
+				toCount++
+			}
+		}
+		fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(hunkFrom, fromCount), hunkRange(hunkTo, toCount))
+		for _, line := range lines[start:end] {
+			b.WriteByte(byte(line.op))
+			b.WriteString(line.text)
+			b.WriteByte('\n')
+		}
+
+		// advance positions over the remainder of the hunk
+		for _, line := range lines[i:end] {
+			if line.op != diffInsert {
+				fromPos++
+			}
+			if line.op != diffDelete {
+				toPos++
+			}
+		}
+		i = end
+	}
+	return b.String()
+}
+
+//-----------------------------------------------------------------------------
+// ~ Private methods
+//-----------------------------------------------------------------------------
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// diffLines computes a shortest edit script using the myers algorithm
+func diffLines(a, b []string) []diffLine {
+	// strip common prefix and suffix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		lines = append(lines, diffLine{diffEqual, text})
+	}
+	lines = append(lines, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{diffEqual, text})
+	}
+	return lines
+}
+
+func myers(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+2)
+	trace := [][]int{}
+
+	// forward search, remembering v for every d
+search:
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// backtrack
+	lines := []diffLine{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, diffLine{diffEqual, a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				lines = append(lines, diffLine{diffInsert, b[y]})
+			} else {
+				x--
+				lines = append(lines, diffLine{diffDelete, a[x]})
+			}
+		}
+	}
+
+	// reverse
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
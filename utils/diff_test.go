@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	assert.Empty(t, UnifiedDiff("a", "b", "same\n", "same\n"))
+
+	from := strings.Join([]string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}, "\n")
+	to := strings.Join([]string{"1", "two", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13"}, "\n")
+
+	expected := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	assert.Equal(t, expected, UnifiedDiff("a", "b", from, to))
+
+	// changes close to each other share a hunk
+	expected = `--- a
++++ b
+@@ -1,4 +1,3 @@
+-<h1>old</h1>
++<h1>new</h1>
+ <p>text</p>
+-<p>removed</p>
+ <p>end</p>
+`
+	assert.Equal(t, expected, UnifiedDiff("a", "b", "<h1>old</h1>\n<p>text</p>\n<p>removed</p>\n<p>end</p>\n", "<h1>new</h1>\n<p>text</p>\n<p>end</p>\n"))
+
+	expected = `--- a
++++ b
+@@ -0,0 +1,2 @@
++x
++y
+`
+	assert.Equal(t, expected, UnifiedDiff("a", "b", "", "x\ny"))
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+import "syscall"
+
+// DiskSpace returns total and available bytes of the file system holding path
+func DiskSpace(path string) (total uint64, free uint64, err error) {
+	stat := syscall.Statfs_t{}
+	if err = syscall.Statfs(path, &stat); err != nil {
+		return
+	}
+	total = stat.Blocks * uint64(stat.Bsize)
+	free = stat.Bavail * uint64(stat.Bsize)
+	return
+}
@@ -0,0 +1,9 @@
+package utils
+
+import "errors"
+
+// DiskSpace is not supported on windows
+func DiskSpace(path string) (total uint64, free uint64, err error) {
+	err = errors.New("disk space not supported on windows")
+	return
+}